@@ -0,0 +1,50 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// annotation is embedded by the wrappers which attach metadata to an error
+// without changing its message or its code. It forwards Cause, Unwrap, Code
+// and Format to the wrapped error.
+type annotation struct {
+	error
+}
+
+// ownError marks the wrappers as errors of this package for isOwn.
+func (annotation) ownError() {}
+
+// Cause returns the underlying cause of the error.
+func (a annotation) Cause() error { return a.error }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (a annotation) Unwrap() error { return a.error }
+
+// Code returns the error code of the wrapped error, if defined.
+func (a annotation) Code() int {
+	if err, ok := a.error.(interface{ Code() int }); ok {
+		return err.Code()
+	}
+	return ErrCodeNotDefined
+}
+
+// Format implements fmt.Formatter. The %d verb prints the code of the
+// wrapped error, as found by GetCode.
+func (a annotation) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'd':
+		_, _ = io.WriteString(s, strconv.Itoa(GetCode(a.error)))
+	case 'v':
+		if s.Flag('+') {
+			formatCause(s, a.error)
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, a.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", a.Error())
+	}
+}
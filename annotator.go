@@ -0,0 +1,58 @@
+package errors
+
+// An Annotator applies the same message, code and fields to every error
+// passed through it. It is useful when many call sites share an annotation.
+// An Annotator is immutable and safe for concurrent use.
+type Annotator struct {
+	msg    string
+	code   int
+	fields map[string]interface{}
+}
+
+// NewAnnotator returns an Annotator which wraps errors with the supplied
+// message and code. If code is ErrCodeNotDefined, each wrapped error keeps
+// the code of its cause, as with Wrap.
+func NewAnnotator(message string, code int) *Annotator {
+	return &Annotator{msg: message, code: code}
+}
+
+// WithFields returns a copy of the Annotator which also attaches fields, as
+// WithFields does, to every error it wraps. The fields are merged over
+// those of a, so that the new value wins for keys set by both; fields is
+// copied and later changes to it have no effect.
+func (a *Annotator) WithFields(fields map[string]interface{}) *Annotator {
+	merged := make(map[string]interface{}, len(a.fields)+len(fields))
+	for k, v := range a.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Annotator{msg: a.msg, code: a.code, fields: merged}
+}
+
+// Wrap returns an error annotating err with a stack trace
+// at the point Wrap is called, and the Annotator's message, code and
+// fields. Each call creates an independent wrapper.
+// If err is nil, Wrap returns nil.
+func (a *Annotator) Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	checkMessage("Annotator.Wrap", a.msg)
+
+	cErr := annotate(err, a.msg)
+	if a.code != ErrCodeNotDefined {
+		onCode(a.code)
+		cErr.code = int64(a.code)
+	}
+	w := &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
+	}
+	if len(a.fields) > 0 {
+		return WithFields(w, a.fields)
+	}
+	return w
+}
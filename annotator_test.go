@@ -0,0 +1,75 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestAnnotator(t *testing.T) {
+	a := NewAnnotator("query users", 500)
+
+	if got := a.Wrap(nil); got != nil {
+		t.Errorf("Annotator.Wrap(nil): got %#v, expected nil", got)
+	}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{io.EOF, "query users: EOF"},
+		{New("timeout").SetCode(504), "query users: timeout"},
+		{Wrap(io.ErrUnexpectedEOF, "read row"), "query users: read row: unexpected EOF"},
+	}
+
+	var wrapped []error
+	for _, tt := range tests {
+		got := a.Wrap(tt.err)
+		if got.Error() != tt.want {
+			t.Errorf("Annotator.Wrap(%v): got %q, want %q", tt.err, got.Error(), tt.want)
+		}
+		if code := got.(*StackError).Code(); code != 500 {
+			t.Errorf("Annotator.Wrap(%v).Code(): got %d, want %d", tt.err, code, 500)
+		}
+		if top := fmt.Sprintf("%n", got.(*StackError).StackTrace()[0]); top != "TestAnnotator" {
+			t.Errorf("Annotator.Wrap(%v): got top frame %q, want %q", tt.err, top, "TestAnnotator")
+		}
+		wrapped = append(wrapped, got)
+	}
+	if wrapped[0] == wrapped[1] || Cause(wrapped[0]) != io.EOF {
+		t.Errorf("Annotator.Wrap: wrappers are not independent")
+	}
+}
+
+func TestAnnotatorInheritsCode(t *testing.T) {
+	a := NewAnnotator("query users", ErrCodeNotDefined)
+	got := a.Wrap(New("timeout").SetCode(504)).(*StackError).Code()
+	if got != 504 {
+		t.Errorf("Annotator.Wrap(...).Code(): got %d, want %d", got, 504)
+	}
+}
+
+func TestAnnotatorWithFields(t *testing.T) {
+	fields := map[string]interface{}{"table": "users", "shard": 1}
+	base := NewAnnotator("query users", 500)
+	a := base.WithFields(fields).WithFields(map[string]interface{}{"shard": 2})
+	fields["table"] = "changed"
+
+	err := a.Wrap(io.EOF)
+	if got := err.Error(); got != "query users: EOF" {
+		t.Errorf("Annotator.Wrap(io.EOF): got %q, want %q", got, "query users: EOF")
+	}
+	if got := GetCode(err); got != 500 {
+		t.Errorf("Annotator.Wrap(io.EOF): got code %d, want %d", got, 500)
+	}
+	if got, want := Fields(err), map[string]interface{}{"table": "users", "shard": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(Annotator.Wrap(io.EOF)): got %v, want %v", got, want)
+	}
+	if got := Fields(base.Wrap(io.EOF)); got != nil {
+		t.Errorf("Fields of the original Annotator: got %v, want nil", got)
+	}
+	if a.Wrap(nil) != nil {
+		t.Errorf("Annotator.Wrap(nil) with fields: got non-nil, expected nil")
+	}
+}
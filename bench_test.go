@@ -5,6 +5,7 @@ package errors
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	stderrors "errors"
@@ -109,3 +110,92 @@ func BenchmarkStackFormatting(b *testing.B) {
 	}
 	GlobalE = stackStr
 }
+
+// appFrame reports whether f belongs to neither the runtime nor the testing
+// package.
+func appFrame(f Frame) bool {
+	return !strings.HasPrefix(f.name(), "runtime.") && !strings.HasPrefix(f.name(), "testing.")
+}
+
+func BenchmarkCaptureFilter(b *testing.B) {
+	runs := []struct {
+		name string
+		keep func(Frame) bool
+	}{
+		{"all", nil},
+		{"filtered", appFrame},
+	}
+	for _, r := range runs {
+		b.Run(r.name, func(b *testing.B) {
+			SetCaptureFilter(r.keep)
+			defer SetCaptureFilter(nil)
+			var err *MsgCodeErr
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err = yesErrors(0, 10).(*MsgCodeErr)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(len(*err.stack)), "frames/op")
+			GlobalE = err
+		})
+	}
+}
+
+func BenchmarkStackSampleRate(b *testing.B) {
+	for _, n := range []int{1, 100} {
+		b.Run(fmt.Sprintf("rate-%d", n), func(b *testing.B) {
+			SetStackSampleRate(n)
+			defer SetStackSampleRate(0)
+			var err error
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err = yesErrors(0, 10)
+			}
+			b.StopTimer()
+			GlobalE = err
+		})
+	}
+}
+
+func BenchmarkLazyStacks(b *testing.B) {
+	for _, lazy := range []bool{false, true} {
+		name := "eager"
+		if lazy {
+			name = "lazy"
+		}
+		b.Run(name, func(b *testing.B) {
+			SetCaptureFilter(appFrame)
+			SetLazyStacks(lazy)
+			defer SetCaptureFilter(nil)
+			defer SetLazyStacks(false)
+			var err error
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err = yesErrors(0, 10)
+			}
+			b.StopTimer()
+			GlobalE = err
+		})
+	}
+}
+
+func BenchmarkNewLight(b *testing.B) {
+	runs := []struct {
+		name string
+		new  func(int, string) *MsgCodeErr
+	}{
+		{"New", NewWithCode},
+		{"NewLight", NewLight},
+	}
+	for _, r := range runs {
+		b.Run(r.name, func(b *testing.B) {
+			var err error
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err = r.new(404, "not found")
+			}
+			b.StopTimer()
+			GlobalE = err
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package errors
+
+import (
+	"encoding/json"
+	"runtime/debug"
+	"sync"
+)
+
+// buildInfo holds the build information of the running binary, read once by
+// readBuildInfo.
+var buildInfo struct {
+	once sync.Once
+	info *debug.BuildInfo
+	json buildJSON
+}
+
+// buildJSON is the JSON shape of the build information attached by
+// WithBuildInfo.
+type buildJSON struct {
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// readBuildInfo returns the build information of the running binary,
+// reading it on the first call only. It returns nil if the binary was built
+// without module support.
+func readBuildInfo() *debug.BuildInfo {
+	buildInfo.once.Do(func() {
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		buildInfo.info = bi
+		buildInfo.json = buildJSON{Path: bi.Main.Path, Version: bi.Main.Version}
+		vcsBuildInfo(bi, &buildInfo.json)
+	})
+	return buildInfo.info
+}
+
+// WithBuildInfo annotates err with the build information of the running
+// binary, as reported by runtime/debug.ReadBuildInfo: the main module's
+// path and version and, when the binary was built with VCS stamping, its
+// revision. This lets crash reporters match stack traces to the binary
+// which produced them. The information is read once and shared by every
+// annotated error. It is included under the "build" key when the error is
+// marshaled to JSON.
+//
+// If err is nil, or the binary was built without module support,
+// WithBuildInfo returns err unchanged.
+func WithBuildInfo(err error) error {
+	if err == nil {
+		return nil
+	}
+	bi := readBuildInfo()
+	if bi == nil {
+		return err
+	}
+	return &withBuildInfo{annotation{err}, bi}
+}
+
+type withBuildInfo struct {
+	annotation
+	info *debug.BuildInfo
+}
+
+// MarshalJSON implements json.Marshaler. The build information is added
+// to the JSON object encoding the wrapped error.
+func (w *withBuildInfo) MarshalJSON() ([]byte, error) {
+	return w.marshalJSON(0)
+}
+
+func (w *withBuildInfo) marshalJSON(depth int) ([]byte, error) {
+	b, err := marshalError(w.error, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	obj := make(map[string]interface{})
+	if json.Unmarshal(b, &obj) != nil {
+		obj = map[string]interface{}{"message": w.Error()}
+	}
+	obj["build"] = buildInfo.json
+	return json.Marshal(obj)
+}
+
+// BuildInfo returns the build information attached to err by
+// WithBuildInfo, and true. If no layer of err's chain carries build
+// information, BuildInfo returns nil and false.
+func BuildInfo(err error) (*debug.BuildInfo, bool) {
+	for i := 0; err != nil && i < maxDepth; i++ {
+		if w, ok := err.(*withBuildInfo); ok {
+			return w.info, true
+		}
+		err = next(err)
+	}
+	return nil, false
+}
@@ -0,0 +1,57 @@
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import (
+	"encoding/json"
+	"io"
+	"runtime/debug"
+	"testing"
+)
+
+func TestWithBuildInfo(t *testing.T) {
+	if got := WithBuildInfo(nil); got != nil {
+		t.Errorf("WithBuildInfo(nil): got %#v, expected nil", got)
+	}
+	if _, ok := BuildInfo(io.EOF); ok {
+		t.Errorf("BuildInfo(io.EOF): got ok, want false")
+	}
+
+	want, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("binary built without module support")
+	}
+
+	err := Wrap(WithBuildInfo(New("crash")), "outer")
+	got, ok := BuildInfo(err)
+	if !ok || got.Main != want.Main {
+		t.Fatalf("BuildInfo(%v): got (%v, %t), want main module %v", err, got, ok, want.Main)
+	}
+	if other, _ := BuildInfo(WithBuildInfo(io.EOF)); other != got {
+		t.Errorf("WithBuildInfo: build information is not shared between errors")
+	}
+
+	b, jerr := json.Marshal(WithBuildInfo(io.EOF))
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	var obj struct {
+		Message string
+		Build   buildJSON
+	}
+	if jerr := json.Unmarshal(b, &obj); jerr != nil {
+		t.Fatal(jerr)
+	}
+	if obj.Message != "EOF" {
+		t.Errorf("WithBuildInfo JSON %s: got message %q, want %q", b, obj.Message, "EOF")
+	}
+	if obj.Build.Path != want.Main.Path || obj.Build.Version != want.Main.Version {
+		t.Errorf("WithBuildInfo JSON %s: got build %+v, want main module %v", b, obj.Build, want.Main)
+	}
+	for _, s := range want.Settings {
+		if s.Key == "vcs.revision" && obj.Build.Revision != s.Value {
+			t.Errorf("WithBuildInfo JSON %s: got revision %q, want %q", b, obj.Build.Revision, s.Value)
+		}
+	}
+}
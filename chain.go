@@ -0,0 +1,270 @@
+package errors
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// maxDepth bounds the number of links followed when walking an error chain,
+// so that a cyclic chain cannot cause an infinite loop.
+const maxDepth = 100
+
+// next returns the error wrapped by err, preferring Cause over Unwrap.
+// It returns nil if err wraps nothing.
+func next(err error) error {
+	switch e := err.(type) {
+	case interface{ Cause() error }:
+		return e.Cause()
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	}
+	return nil
+}
+
+// Layer is a single link of an error chain, as returned by Layers.
+type Layer interface {
+	Code() int
+	Error() string
+}
+
+// Layers returns every link of err's chain, from outermost to innermost,
+// following Cause and Unwrap. Links which do not report a code are adapted
+// to report ErrCodeNotDefined. If err is nil, Layers returns nil.
+func Layers(err error) []Layer {
+	var layers []Layer
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if l, ok := err.(Layer); ok {
+			layers = append(layers, l)
+			continue
+		}
+		layers = append(layers, plainLayer{err})
+	}
+	return layers
+}
+
+// plainLayer adapts an error without a code to the Layer interface.
+type plainLayer struct {
+	error
+}
+
+// Code returns ErrCodeNotDefined.
+func (plainLayer) Code() int { return ErrCodeNotDefined }
+
+// walk calls fn for every error in err's chain, from outermost to
+// innermost, following Cause and Unwrap, until fn returns false. Errors
+// which wrap several errors by implementing
+//
+//	Unwrap() []error
+//
+// are walked depth-first: each branch is walked completely, in order,
+// before the next. At most maxDepth errors are visited in total, so that
+// cyclic chains cannot cause an infinite loop. walk reports whether every
+// visited error was accepted by fn.
+func walk(err error, fn func(error) bool) bool {
+	budget := maxDepth
+	return walkBranch(err, fn, &budget)
+}
+
+func walkBranch(err error, fn func(error) bool, budget *int) bool {
+	for ; err != nil && *budget > 0; err = next(err) {
+		*budget--
+		if !fn(err) {
+			return false
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok && next(err) == nil {
+			for _, branch := range multi.Unwrap() {
+				if !walkBranch(branch, fn, budget) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return true
+}
+
+// ChainLen returns the number of errors in err's chain, err included,
+// following Cause and Unwrap. The errors wrapped by errors implementing
+// Unwrap() []error, such as those returned by Join, are all counted. Like
+// the other functions walking chains, it visits at most maxDepth (100)
+// errors, so it returns at most 100 for cyclic chains. If err is nil,
+// ChainLen returns 0.
+func ChainLen(err error) int {
+	n := 0
+	walk(err, func(error) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// maxChainDepth holds the depth set by SetMaxChainDepth.
+var maxChainDepth int32
+
+// SetMaxChainDepth bounds the memory used by chains which grow without
+// limit, as when an error is wrapped again on every iteration of a loop.
+// Once the chain being wrapped is n errors long, further wrapping still
+// adds messages and codes but records no stack trace. A depth of zero or
+// less, the default, records every stack trace.
+//
+// SetMaxChainDepth should be called during program initialisation.
+func SetMaxChainDepth(n int) {
+	if n < 0 {
+		n = 0
+	}
+	atomic.StoreInt32(&maxChainDepth, int32(n))
+}
+
+// root returns the innermost error of err's chain, following Cause and
+// Unwrap. It returns nil if err is nil or if the chain does not end within
+// maxDepth links, as happens for cyclic chains.
+func root(err error) error {
+	for i := 0; err != nil && i < maxDepth; i++ {
+		cause := next(err)
+		if cause == nil {
+			return err
+		}
+		err = cause
+	}
+	return nil
+}
+
+// IsRoot reports whether err is the root of its chain: it wraps no other
+// error, either through Cause, Unwrap() error, or Unwrap() []error. Errors
+// created by New and the other constructors of this package are always
+// roots; those created by Wrap and its relatives never are. IsRoot returns
+// false if err is nil.
+func IsRoot(err error) bool {
+	if err == nil {
+		return false
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok && len(multi.Unwrap()) > 0 {
+		return false
+	}
+	return next(err) == nil
+}
+
+// isOwn reports whether err is one of the error types of this package.
+func isOwn(err error) bool {
+	switch err.(type) {
+	case *MsgCodeErr, *CauseMsgCodeError, *StackError, *multiError, countedErrors:
+		return true
+	case interface{ ownError() }:
+		return true
+	}
+	return false
+}
+
+// Root returns the first error in err's chain whose type is not defined by
+// this package, following Cause and Unwrap past the layers added by Wrap,
+// WithMessage and the other functions of this package. This is the foreign
+// error those layers were wrapped around, such as an *os.PathError, and can
+// be type-asserted reliably however many times it was wrapped. Errors that
+// the foreign error itself wraps are not inspected.
+//
+// Unlike Cause, which follows Cause methods until reaching an error without
+// one, Root stops at the first foreign error, even one which implements
+// Cause. If err is nil, or every error in its chain is defined by this
+// package, Root returns nil.
+func Root(err error) error {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if !isOwn(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShareCause reports whether a and b have the same root cause: the
+// innermost errors of their chains, found by following Cause and Unwrap,
+// are the same value. Roots are compared by identity, so two distinct errors
+// with equal messages do not share a cause. Roots whose dynamic type is not
+// comparable are never considered the same.
+//
+// ShareCause returns false if either error is nil or has a cyclic chain.
+func ShareCause(a, b error) bool {
+	ra, rb := root(a), root(b)
+	if ra == nil || rb == nil {
+		return false
+	}
+	if reflect.TypeOf(ra) != reflect.TypeOf(rb) || !reflect.TypeOf(ra).Comparable() {
+		return false
+	}
+	return ra == rb
+}
+
+// IsPureStack reports whether err is a layer which adds nothing to the
+// error it wraps but a stack trace, as created by WithStack. It is false for
+// the errors returned by Wrap and similar functions, which also add a
+// message, and for nil.
+func IsPureStack(err error) bool {
+	w, ok := err.(*StackError)
+	return ok && w != nil && w.stackOnly
+}
+
+// Find returns the first error in err's chain for which pred returns true.
+// The chain is walked from outermost to innermost, following Cause and
+// Unwrap. Errors which wrap several errors by implementing
+//
+//	Unwrap() []error
+//
+// are searched depth-first: each branch is searched completely, in order,
+// before the next. An error reached more than once, as happens for cyclic
+// chains, is only tested the first time, and each branch is followed for
+// at most maxDepth links.
+//
+// If err is nil or no error matches, Find returns nil, false.
+func Find(err error, pred func(error) bool) (error, bool) {
+	seen := make(map[error]bool)
+	return find(err, pred, seen, 0)
+}
+
+func find(err error, pred func(error) bool, seen map[error]bool, depth int) (error, bool) {
+	for ; err != nil && depth < maxDepth; depth++ {
+		if reflect.TypeOf(err).Comparable() {
+			if seen[err] {
+				return nil, false
+			}
+			seen[err] = true
+		}
+		if pred(err) {
+			return err, true
+		}
+		if multi, ok := err.(interface{ Unwrap() []error }); ok && next(err) == nil {
+			for _, branch := range multi.Unwrap() {
+				if found, ok := find(branch, pred, seen, depth+1); ok {
+					return found, true
+				}
+			}
+			return nil, false
+		}
+		err = next(err)
+	}
+	return nil, false
+}
+
+// AsMsgCodeErr returns the first *MsgCodeErr in err's chain, following
+// Cause and Unwrap, and true. If there is none, or err is nil, it returns
+// nil and false.
+func AsMsgCodeErr(err error) (*MsgCodeErr, bool) {
+	for i := 0; err != nil && i < maxDepth; i++ {
+		if e, ok := err.(*MsgCodeErr); ok && e != nil {
+			return e, true
+		}
+		err = next(err)
+	}
+	return nil, false
+}
+
+// AsCauseMsgCodeError returns the first *CauseMsgCodeError in err's chain,
+// following Cause and Unwrap, and true. If there is none, or err is nil,
+// it returns nil and false.
+func AsCauseMsgCodeError(err error) (*CauseMsgCodeError, bool) {
+	for i := 0; err != nil && i < maxDepth; i++ {
+		if e, ok := err.(*CauseMsgCodeError); ok && e != nil {
+			return e, true
+		}
+		err = next(err)
+	}
+	return nil, false
+}
@@ -0,0 +1,333 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	if got := Layers(nil); got != nil {
+		t.Errorf("Layers(nil): got %v, want nil", got)
+	}
+
+	err := WithMessage(Wrap(New("root").SetCode(404), "middle"), "outer")
+	type layer struct {
+		code int
+		msg  string
+	}
+	want := []layer{
+		{404, "outer: middle: root"},
+		{404, "middle: root"},
+		{404, "middle: root"},
+		{404, "root"},
+	}
+
+	got := Layers(err)
+	if len(got) != len(want) {
+		t.Fatalf("Layers(%v): got %d layers, want %d", err, len(got), len(want))
+	}
+	for i, l := range got {
+		if l.Code() != want[i].code || l.Error() != want[i].msg {
+			t.Errorf("layer %d: got (%d, %q), want (%d, %q)", i, l.Code(), l.Error(), want[i].code, want[i].msg)
+		}
+	}
+}
+
+func TestLayersForeign(t *testing.T) {
+	err := Wrap(fmt.Errorf("read: %w", io.EOF), "load config").SetCode(500)
+
+	var codes []int
+	for _, l := range Layers(err) {
+		codes = append(codes, l.Code())
+	}
+	want := []int{500, 500, ErrCodeNotDefined, ErrCodeNotDefined}
+	if fmt.Sprint(codes) != fmt.Sprint(want) {
+		t.Errorf("Layers(%v) codes: got %v, want %v", err, codes, want)
+	}
+}
+
+// cyclic is an error whose Cause is another cyclic error, which may be
+// itself, allowing tests to build cyclic chains.
+type cyclic struct {
+	cause *cyclic
+}
+
+func (c *cyclic) Error() string { return "cyclic" }
+func (c *cyclic) Cause() error  { return c.cause }
+
+func TestShareCause(t *testing.T) {
+	root := New("root")
+	a, b := &cyclic{}, &cyclic{}
+	a.cause, b.cause = b, a
+
+	tests := []struct {
+		a, b error
+		want bool
+	}{
+		{nil, nil, false},
+		{root, nil, false},
+		{root, root, true},
+		{Wrap(root, "a"), WithMessage(WithStack(root), "b"), true},
+		{Wrap(root, "a"), fmt.Errorf("b: %w", root), true},
+		{Wrap(New("root"), "a"), Wrap(root, "a"), false},
+		{Wrap(io.EOF, "a"), io.EOF, true},
+		{joined{io.EOF}, joined{io.EOF}, false},
+		{a, a, false},
+		{a, root, false},
+	}
+
+	for i, tt := range tests {
+		got := ShareCause(tt.a, tt.b)
+		if got != tt.want {
+			t.Errorf("test %d: ShareCause(%v, %v): got %t, want %t", i+1, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIsPureStack(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{New("x"), false},
+		{WithStack(io.EOF), true},
+		{WithStack(WithMessage(io.EOF, "read")), true},
+		{WithStack(Wrap(io.EOF, "read")), true},
+		{Wrap(io.EOF, "read"), false},
+		{Wrapf(WithStack(io.EOF), "read %d", 1), false},
+		{Codef(404, io.EOF, "read"), false},
+		{WithMessage(WithStack(io.EOF), "read"), false},
+	}
+
+	for i, tt := range tests {
+		got := IsPureStack(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: IsPureStack(%v): got %t, want %t", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	eof := Wrap(io.EOF, "read")
+	branch := joined{New("first"), Wrap(New("deep").SetCode(42), "second")}
+	a, b := &cyclic{}, &cyclic{}
+	a.cause, b.cause = b, a
+
+	hasCode := func(err error) bool {
+		cErr, ok := err.(interface{ Code() int })
+		return ok && cErr.Code() == 42
+	}
+	isEOF := func(err error) bool { return err == io.EOF }
+
+	tests := []struct {
+		err  error
+		pred func(error) bool
+		want error
+	}{
+		{nil, isEOF, nil},
+		{eof, isEOF, io.EOF},
+		{New("other"), isEOF, nil},
+		{Wrap(branch, "outer"), isEOF, nil},
+		{a, isEOF, nil},
+		{joined{New("x"), eof}, isEOF, io.EOF},
+	}
+
+	for i, tt := range tests {
+		got, ok := Find(tt.err, tt.pred)
+		if got != tt.want || ok != (tt.want != nil) {
+			t.Errorf("test %d: Find(%v): got (%v, %t), want %v", i+1, tt.err, got, ok, tt.want)
+		}
+	}
+
+	got, ok := Find(Wrap(branch, "outer"), hasCode)
+	if !ok || got.Error() != "second: deep" {
+		t.Errorf("Find(joined, hasCode): got (%v, %t), want the outermost layer with code 42", got, ok)
+	}
+}
+
+func TestAsMsgCodeErr(t *testing.T) {
+	leaf := New("row missing").SetCode(404).(*MsgCodeErr)
+	inner := WithMessage(WithStack(leaf), "query")
+	err := Wrap(WithHTTPStatus(inner, 404), "load user")
+
+	if got, ok := AsMsgCodeErr(err); !ok || got != leaf {
+		t.Errorf("AsMsgCodeErr(%v): got (%v, %t), want (%v, true)", err, got, ok, leaf)
+	}
+	cErr, ok := AsCauseMsgCodeError(err)
+	if !ok || cErr.Error() != "load user: query: row missing" {
+		t.Errorf("AsCauseMsgCodeError(%v): got (%v, %t), want the outermost message layer", err, cErr, ok)
+	}
+	if got, ok := AsCauseMsgCodeError(WithStack(leaf)); ok {
+		t.Errorf("AsCauseMsgCodeError(WithStack(leaf)): got (%v, true), want false", got)
+	}
+
+	for _, err := range []error{nil, io.EOF, fmt.Errorf("wrapped: %v", leaf)} {
+		if got, ok := AsMsgCodeErr(err); ok {
+			t.Errorf("AsMsgCodeErr(%v): got (%v, true), want false", err, got)
+		}
+		if got, ok := AsCauseMsgCodeError(err); ok {
+			t.Errorf("AsCauseMsgCodeError(%v): got (%v, true), want false", err, got)
+		}
+	}
+}
+
+func TestCyclicChain(t *testing.T) {
+	a, b := &cyclic{}, &cyclic{}
+	a.cause, b.cause = b, a
+
+	if got := Cause(a); got != b {
+		t.Errorf("Cause(a): got %p, want the last error before the cycle repeats (%p)", got, b)
+	}
+	if got := Cause(Wrap(a, "outer")); got != b {
+		t.Errorf("Cause(Wrap(a)): got %p, want %p", got, b)
+	}
+	self := &cyclic{}
+	self.cause = self
+	if got := Cause(self); got != self {
+		t.Errorf("Cause(self): got %p, want %p", got, self)
+	}
+
+	if got := GetCode(a); got != ErrCodeNotDefined {
+		t.Errorf("GetCode(a): got %d, want %d", got, ErrCodeNotDefined)
+	}
+	if got := len(Layers(a)); got != maxDepth {
+		t.Errorf("Layers(a): got %d layers, want %d", got, maxDepth)
+	}
+	_ = HTTPStatus(a)
+	_ = Fields(a)
+	_ = Tree(a)
+	_ = Message(a)
+	_ = ContextMessage(a)
+}
+
+func TestIsRoot(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{New("root"), true},
+		{Errorf("root %d", 1), true},
+		{NewCode(404), true},
+		{Wrap(io.EOF, "wrapped"), false},
+		{WithStack(New("root")), false},
+		{WithMessage(io.EOF, "outer"), false},
+		{WithHTTPStatus(io.EOF, 404), false},
+		{fmt.Errorf("wrapped: %w", io.EOF), false},
+		{Join(io.EOF, New("root")), false},
+	}
+
+	for i, tt := range tests {
+		if got := IsRoot(tt.err); got != tt.want {
+			t.Errorf("test %d: IsRoot(%v): got %t, want %t", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	if got := Unwrap(New("root")); got != nil {
+		t.Errorf("Unwrap(New(\"root\")): got %v, want nil", got)
+	}
+}
+
+func TestChainLen(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{io.EOF, 1},
+		{New("root"), 1},
+		{WithMessage(io.EOF, "read"), 2},
+		{Wrap(io.EOF, "read"), 3},
+		{WithHTTPStatus(WithStack(New("root")), 500), 3},
+	}
+
+	for i, tt := range tests {
+		if got := ChainLen(tt.err); got != tt.want {
+			t.Errorf("test %d: ChainLen(%v): got %d, want %d", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSetMaxChainDepth(t *testing.T) {
+	SetMaxChainDepth(4)
+	defer SetMaxChainDepth(0)
+
+	var err error = New("root")
+	var traces []bool
+	for i := 0; i < 4; i++ {
+		wrapped := Wrap(err, "retry")
+		traces = append(traces, len(wrapped.StackTrace()) > 0)
+		err = wrapped
+	}
+	want := []bool{true, true, false, false}
+	if fmt.Sprint(traces) != fmt.Sprint(want) {
+		t.Errorf("SetMaxChainDepth(4): got stack traces %v, want %v", traces, want)
+	}
+	if got := ChainLen(err); got != 9 {
+		t.Errorf("SetMaxChainDepth(4): got chain length %d, want 9", got)
+	}
+
+	SetMaxChainDepth(2)
+	one, two := io.EOF, WithMessageCode(io.EOF, 500, "read")
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{Wrap(one, "retry"), true},
+		{WithStack(one), true},
+		{WithStackSkip(one, 0), true},
+		{NewStack(one), true},
+		{WithMessage(one, "retry"), true},
+		{Wrap(two, "retry"), false},
+		{WithStack(two), false},
+		{WithStackSkip(two, 0), false},
+		{NewStack(two), false},
+		{WithMessage(two, "retry"), false},
+	}
+	for i, tt := range tests {
+		if got := len(GetStackTrace(tt.err)) > 0; got != tt.want {
+			t.Errorf("test %d: SetMaxChainDepth(2): %v: got stack trace %t, want %t", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	SetMaxChainDepth(0)
+	if len(Wrap(err, "retry").StackTrace()) == 0 {
+		t.Errorf("SetMaxChainDepth(0): got no stack trace")
+	}
+}
+
+func TestRoot(t *testing.T) {
+	_, pathErr := os.Open("/nonexistent/errors/root")
+	if _, ok := pathErr.(*os.PathError); !ok {
+		t.Fatalf("os.Open: got %T, want *os.PathError", pathErr)
+	}
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{New("root"), nil},
+		{Wrap(New("root"), "wrapped"), nil},
+		{io.EOF, io.EOF},
+		{pathErr, pathErr},
+		{Wrap(pathErr, "open config"), pathErr},
+		{WithMessage(Wrap(pathErr, "open config"), "load"), pathErr},
+		{WithHTTPStatus(WithStack(pathErr), 404), pathErr},
+	}
+
+	for i, tt := range tests {
+		if got := Root(tt.err); got != tt.want {
+			t.Errorf("test %d: Root(%v): got %#v, want %#v", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	err := Wrap(WithMessage(pathErr, "open config"), "load")
+	if _, ok := Root(err).(*os.PathError); !ok {
+		t.Errorf("Root(%v): got %T, want *os.PathError", err, Root(err))
+	}
+}
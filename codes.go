@@ -0,0 +1,408 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CodeError is implemented by errors which carry a code that can be read
+// and changed. MsgCodeErr, CauseMsgCodeError and StackError implement it.
+type CodeError interface {
+	error
+	Code() int
+	SetCode(int) error
+}
+
+// IsCodeError returns the first error in err's chain which implements
+// CodeError, following Cause and Unwrap, and true. If there is none, or err
+// is nil, it returns nil and false.
+func IsCodeError(err error) (CodeError, bool) {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if cErr, ok := err.(CodeError); ok {
+			return cErr, true
+		}
+	}
+	return nil, false
+}
+
+// codeMessages holds the default messages registered with
+// RegisterCodeMessage.
+var codeMessages = struct {
+	sync.RWMutex
+	m map[int]string
+}{m: make(map[int]string)}
+
+// RegisterCodeMessage registers message as the default message for errors
+// created by NewCode with the given code, replacing any previous
+// registration. It is safe for concurrent use, but is usually called from
+// init functions.
+func RegisterCodeMessage(code int, message string) {
+	codeMessages.Lock()
+	defer codeMessages.Unlock()
+	codeMessages.m[code] = message
+}
+
+// codeMessage returns the message registered for code, or a generic
+// message naming the code if there is none.
+func codeMessage(code int) string {
+	codeMessages.RLock()
+	defer codeMessages.RUnlock()
+	if msg, ok := codeMessages.m[code]; ok {
+		return msg
+	}
+	return "error code " + strconv.Itoa(code)
+}
+
+// NewCode returns an error with the supplied code and the default message
+// registered for it with RegisterCodeMessage. If no message is registered
+// for code, the message is "error code <code>".
+// NewCode also records the stack trace at the point it was called.
+func NewCode(code int) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:    codeMessage(code),
+		code:   int64(code),
+		fields: scopeFields(),
+	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
+}
+
+// codeNames holds the codes registered with RegisterCode.
+var codeNames = struct {
+	sync.RWMutex
+	m map[int]string
+}{m: make(map[int]string)}
+
+func init() {
+	RegisterCode(ErrCodeOK, "OK")
+	RegisterCode(ErrCodeFailed, "FAILED")
+}
+
+// RegisterCode adds code to the catalogue of sanctioned codes under the
+// given name. The predefined ErrCodeOK and ErrCodeFailed are registered by
+// the package. RegisterCode panics if code is ErrCodeNotDefined or is
+// already registered, so that collisions are caught early. It is safe for
+// concurrent use, but is usually called from init functions.
+func RegisterCode(code int, name string) {
+	if code == ErrCodeNotDefined {
+		panic("errors: RegisterCode called with ErrCodeNotDefined")
+	}
+	codeNames.Lock()
+	defer codeNames.Unlock()
+	if prev, ok := codeNames.m[code]; ok {
+		panic("errors: code " + strconv.Itoa(code) + " registered twice, as " + prev + " and " + name)
+	}
+	codeNames.m[code] = name
+}
+
+// registeredCodeName returns the name code was registered with.
+func registeredCodeName(code int) (string, bool) {
+	codeNames.RLock()
+	defer codeNames.RUnlock()
+	name, ok := codeNames.m[code]
+	return name, ok
+}
+
+// CodeName returns the name code was registered with by RegisterCode, or
+// "" if code is not registered. The name is shown next to the code in the
+// output of the %+v verb.
+func CodeName(code int) string {
+	name, _ := registeredCodeName(code)
+	return name
+}
+
+// CodeChain returns the code of each link of err's chain, from outermost to
+// innermost, following Cause and Unwrap as Layers does. Links which carry
+// no code report ErrCodeNotDefined, so that the result shows where a code
+// was introduced or replaced by a wrap. If err is nil, CodeChain returns
+// nil.
+func CodeChain(err error) []int {
+	var codes []int
+	for _, l := range Layers(err) {
+		codes = append(codes, l.Code())
+	}
+	return codes
+}
+
+// CodeNameChain returns the codes of CodeChain in readable form: the name
+// registered with RegisterCode for each code, or the code in decimal if it
+// is not registered. If err is nil, CodeNameChain returns nil.
+func CodeNameChain(err error) []string {
+	var names []string
+	for _, code := range CodeChain(err) {
+		name, ok := registeredCodeName(code)
+		if !ok {
+			name = strconv.Itoa(code)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ValidateCode returns an error describing the problem if the code of err,
+// as returned by GetCode, is neither ErrCodeNotDefined nor a code
+// registered with RegisterCode. It returns nil if the code is valid or err
+// is nil.
+//
+// ValidateCode is a development aid, intended for tests and debug builds to
+// enforce that every code comes from the registered catalogue. It is not
+// meant to be called on hot paths in production.
+func ValidateCode(err error) error {
+	code := GetCode(err)
+	if code == ErrCodeNotDefined {
+		return nil
+	}
+	if _, ok := registeredCodeName(code); ok {
+		return nil
+	}
+	return Errorf("errors: unregistered code %d on error %q", code, err.Error())
+}
+
+// TrySetCode sets the code of err to code. If err, or an error in its
+// chain, implements
+//
+//	interface {
+//	        SetCode(int) error
+//	}
+//
+// the code is set on the outermost such error which can carry it, and
+// TrySetCode returns err and true. Layers whose SetCode has no effect, such
+// as a StackError wrapping an error without a code, are skipped. Otherwise
+// err is wrapped in a layer carrying the code, and
+// TrySetCode returns the wrapper and false. Either way, the returned error
+// carries the code; the boolean reports whether wrapping was avoided.
+// If err is nil, TrySetCode returns nil and false.
+func TrySetCode(err error, code int) (error, bool) {
+	if err == nil {
+		return nil, false
+	}
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		if cErr, ok := e.(interface{ SetCode(int) error }); ok {
+			_ = cErr.SetCode(code)
+			if c, ok := e.(interface{ Code() int }); !ok || c.Code() == code {
+				return err, true
+			}
+		}
+	}
+	onCode(code)
+	return &withCode{code: int64(code), annotation: annotation{err}}, false
+}
+
+// withCode attaches a code to an error which cannot carry one itself.
+type withCode struct {
+	// code is accessed atomically; it comes first to keep it 64-bit
+	// aligned on 32-bit platforms.
+	code int64
+	annotation
+}
+
+// Code returns the error code.
+func (w *withCode) Code() int { return int(atomic.LoadInt64(&w.code)) }
+
+// Format implements fmt.Formatter. The %d verb prints the error code.
+func (w *withCode) Format(s fmt.State, verb rune) {
+	if verb == 'd' {
+		_, _ = io.WriteString(s, strconv.Itoa(w.Code()))
+		return
+	}
+	w.annotation.Format(s, verb)
+}
+
+// SetCode sets the error code.
+func (w *withCode) SetCode(code int) error {
+	atomic.StoreInt64(&w.code, int64(code))
+	onCode(code)
+	return w
+}
+
+// CodeInheritance is a policy for the code given to the errors created by
+// Wrap, WithMessage and their relatives which do not take a code.
+type CodeInheritance int
+
+// Code inheritance policies, for use with SetCodeInheritance.
+const (
+	// InheritCode gives the new error the code of the error it wraps.
+	InheritCode CodeInheritance = iota
+
+	// ClearCode gives the new error ErrCodeNotDefined.
+	ClearCode
+
+	// DeepestCode gives the new error the innermost defined code in the
+	// chain of the error it wraps, as PromoteCode does.
+	DeepestCode
+)
+
+// codeInheritance holds the policy set by SetCodeInheritance.
+var codeInheritance int32
+
+// SetCodeInheritance sets the policy deciding the code of the errors
+// created by Wrap, Wrapf, WithMessage, WithMessagef and the other functions
+// which add a message without taking a code. The default is InheritCode.
+// The code the wrapped error had, reported by CauseCode, is recorded
+// whatever the policy.
+//
+// SetCodeInheritance only affects errors created after it is called. It
+// should be called during program initialisation.
+func SetCodeInheritance(mode CodeInheritance) {
+	atomic.StoreInt32(&codeInheritance, int32(mode))
+}
+
+// deepestCode returns the innermost defined code in err's chain, or
+// ErrCodeNotDefined if there is none.
+func deepestCode(err error) int {
+	code := ErrCodeNotDefined
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if cErr, ok := err.(interface{ Code() int }); ok && cErr.Code() != ErrCodeNotDefined {
+			code = cErr.Code()
+		}
+	}
+	return code
+}
+
+// PromoteCode returns an error whose Code method reports the innermost
+// defined code in err's chain, for when a generic outer layer hides the
+// meaningful code of an inner one. If the outermost error already reports
+// that code, or no layer has a defined code, PromoteCode returns err
+// unchanged. Otherwise err is wrapped in a layer carrying the code; err
+// itself is not modified, and its message is unchanged.
+// If err is nil, PromoteCode returns nil.
+func PromoteCode(err error) error {
+	code := deepestCode(err)
+	if code == ErrCodeNotDefined {
+		return err
+	}
+	if cErr, ok := err.(interface{ Code() int }); ok && cErr.Code() == code {
+		return err
+	}
+	return &withCode{code: int64(code), annotation: annotation{err}}
+}
+
+// CodeInRange reports whether the code of err, as returned by GetCode, lies
+// within [lo, hi]. It returns false if err is nil or has no code, even when
+// ErrCodeNotDefined lies within the range.
+func CodeInRange(err error, lo, hi int) bool {
+	code := GetCode(err)
+	if code == ErrCodeNotDefined {
+		return false
+	}
+	return lo <= code && code <= hi
+}
+
+// codeCategories holds the ranges registered with RegisterCategory.
+var codeCategories struct {
+	sync.RWMutex
+	ranges []codeRange
+}
+
+// codeRange is a named range of codes, [low, high].
+type codeRange struct {
+	name      string
+	low, high int
+}
+
+// RegisterCategory names the range of codes [low, high], so that Category
+// reports name for errors whose code lies within it. RegisterCategory
+// panics if low is greater than high or if the range overlaps one already
+// registered, so that collisions are caught early. It is safe for
+// concurrent use, but is usually called from init functions.
+func RegisterCategory(name string, low, high int) {
+	if low > high {
+		panic("errors: RegisterCategory called with an empty range for " + name)
+	}
+	codeCategories.Lock()
+	defer codeCategories.Unlock()
+	for _, r := range codeCategories.ranges {
+		if low <= r.high && r.low <= high {
+			panic("errors: category " + name + " overlaps category " + r.name)
+		}
+	}
+	codeCategories.ranges = append(codeCategories.ranges, codeRange{name, low, high})
+}
+
+// Category returns the name of the category registered with
+// RegisterCategory whose range contains the code of err, as returned by
+// GetCode. It returns "" if err is nil, has no code, or its code lies in no
+// registered range.
+func Category(err error) string {
+	code := GetCode(err)
+	if code == ErrCodeNotDefined {
+		return ""
+	}
+	codeCategories.RLock()
+	defer codeCategories.RUnlock()
+	for _, r := range codeCategories.ranges {
+		if r.low <= code && code <= r.high {
+			return r.name
+		}
+	}
+	return ""
+}
+
+// CauseCode returns the code the cause of err had when err was created by
+// wrapping it, as recorded by the outermost error in the chain which
+// implements
+//
+//	interface {
+//	        CauseCode() int
+//	}
+//
+// Together with the code of err, it distinguishes how a layer classified an
+// error from how the underlying failure was classified. It returns
+// ErrCodeNotDefined if no such layer exists or err is nil.
+func CauseCode(err error) int {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if cErr, ok := err.(interface{ CauseCode() int }); ok {
+			return cErr.CauseCode()
+		}
+	}
+	return ErrCodeNotDefined
+}
+
+// GetCode returns the first defined code in err's chain, walking it from
+// outermost to innermost by Cause and Unwrap and skipping layers which
+// report ErrCodeNotDefined. Unlike calling Code on err directly, it finds a
+// code set deep in the chain even when the layers above it carry none.
+// Errors which wrap several errors by implementing
+//
+//	Unwrap() []error
+//
+// such as those returned by Join and the standard library's Join, are
+// searched depth-first: each branch is searched completely, in order,
+// before the next, and the first code found wins. GetCode returns
+// ErrCodeNotDefined if no layer has a code or err is nil.
+func GetCode(err error) int {
+	code := ErrCodeNotDefined
+	walk(err, func(err error) bool {
+		if _, ok := err.(*StackError); ok {
+			// StackError forwards the code of its cause, which is
+			// visited next.
+			return true
+		}
+		if cErr, ok := err.(interface{ Code() int }); ok && cErr.Code() != ErrCodeNotDefined {
+			code = cErr.Code()
+			return false
+		}
+		return true
+	})
+	return code
+}
+
+// HasCode reports whether any layer of err's chain reports code, walking
+// the chain as GetCode does, through every branch of joined errors. It
+// returns false if err is nil or code is ErrCodeNotDefined.
+func HasCode(err error, code int) bool {
+	if code == ErrCodeNotDefined {
+		return false
+	}
+	return !walk(err, func(err error) bool {
+		if _, ok := err.(*StackError); ok {
+			return true
+		}
+		cErr, ok := err.(interface{ Code() int })
+		return !ok || cErr.Code() != code
+	})
+}
@@ -0,0 +1,435 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// The codes used by the tests are registered once, as RegisterCode panics
+// on a second registration and the tests may run more than once.
+func init() {
+	RegisterCode(-1000, "TEST_DUPLICATE")
+	RegisterCode(-1001, "TEST_VALID")
+	RegisterCode(-1010, "TEST_NOT_FOUND")
+	RegisterCategory("auth", 91000, 91999)
+	RegisterCategory("db", 92000, 92999)
+	RegisterCode(-8001, "TEST_TIMEOUT")
+}
+
+func TestNewCode(t *testing.T) {
+	RegisterCodeMessage(404, "resource not found")
+
+	tests := []struct {
+		code int
+		want string
+	}{
+		{404, "resource not found"},
+		{-42, "error code -42"},
+		{ErrCodeFailed, "error code 1"},
+	}
+
+	for _, tt := range tests {
+		got := NewCode(tt.code)
+		if got.Error() != tt.want {
+			t.Errorf("NewCode(%d): got %q, want %q", tt.code, got.Error(), tt.want)
+		}
+		if got.Code() != tt.code {
+			t.Errorf("NewCode(%d).Code(): got %d, want %d", tt.code, got.Code(), tt.code)
+		}
+		if top := fmt.Sprintf("%n", got.StackTrace()[0]); top != "TestNewCode" {
+			t.Errorf("NewCode(%d): got top frame %q, want %q", tt.code, top, "TestNewCode")
+		}
+	}
+}
+
+func TestRegisterCodePanics(t *testing.T) {
+	for _, code := range []int{-1000, ErrCodeOK, ErrCodeNotDefined} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterCode(%d, ...): expected panic", code)
+				}
+			}()
+			RegisterCode(code, "AGAIN")
+		}()
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	tests := []struct {
+		err     error
+		wantErr bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{New("no code"), false},
+		{New("ok").SetCode(ErrCodeOK), false},
+		{Wrap(New("registered").SetCode(-1001), "wrapped"), false},
+		{New("unregistered").SetCode(-1002), true},
+		{Wrap(New("unregistered").SetCode(-1002), "wrapped"), true},
+		{fmt.Errorf("foreign: %w", New("registered").SetCode(-1001)), false},
+		{fmt.Errorf("foreign: %w", New("unregistered").SetCode(-1002)), true},
+	}
+
+	for i, tt := range tests {
+		got := ValidateCode(tt.err)
+		if (got != nil) != tt.wantErr {
+			t.Errorf("test %d: ValidateCode(%v): got %v, want error: %t", i+1, tt.err, got, tt.wantErr)
+		}
+	}
+}
+
+func TestTrySetCode(t *testing.T) {
+	if got, ok := TrySetCode(nil, 404); got != nil || ok {
+		t.Errorf("TrySetCode(nil, 404): got (%v, %t), want (nil, false)", got, ok)
+	}
+
+	coded := New("x")
+	annotated := WithHTTPStatus(Wrap(New("y"), "wrapped"), 404)
+	tests := []struct {
+		err      error
+		wantSame bool
+	}{
+		{coded, true},
+		{Wrap(io.EOF, "wrapped"), true},
+		{annotated, true},
+		{io.EOF, false},
+		{fmt.Errorf("foreign: %w", io.EOF), false},
+		{WithStack(io.EOF), false},
+		{WithStack(New("z")), true},
+	}
+
+	for i, tt := range tests {
+		got, ok := TrySetCode(tt.err, 409)
+		if ok != tt.wantSame || (got == tt.err) != tt.wantSame {
+			t.Errorf("test %d: TrySetCode(%v, 409): got (%v, %t), want same error: %t", i+1, tt.err, got, ok, tt.wantSame)
+		}
+		if code := got.(interface{ Code() int }).Code(); code != 409 {
+			t.Errorf("test %d: TrySetCode(%v, 409): got code %d, want %d", i+1, tt.err, code, 409)
+		}
+		if got.Error() != tt.err.Error() {
+			t.Errorf("test %d: TrySetCode(%v, 409): got message %q, want %q", i+1, tt.err, got.Error(), tt.err.Error())
+		}
+	}
+
+	if coded.Code() != 409 {
+		t.Errorf("TrySetCode(%v, 409): code not set in place", coded)
+	}
+	if annotated.(interface{ Code() int }).Code() != 409 {
+		t.Errorf("TrySetCode(%v, 409): code not visible through annotation", annotated)
+	}
+}
+
+func TestPromoteCode(t *testing.T) {
+	inner := New("not found").SetCode(404)
+	generic := Wrap(inner, "handle request")
+	_ = generic.error.(*CauseMsgCodeError).SetCode(500)
+
+	tests := []struct {
+		err       error
+		want      int
+		unchanged bool
+	}{
+		{nil, ErrCodeNotDefined, true},
+		{io.EOF, ErrCodeNotDefined, true},
+		{New("no code"), ErrCodeNotDefined, true},
+		{inner, 404, true},
+		{Wrap(inner, "inherits"), 404, true},
+		{generic, 404, false},
+		{WithMessage(New("reset").SetCode(ErrCodeNotDefined), "outer").SetCode(7), 7, true},
+	}
+
+	for i, tt := range tests {
+		got := PromoteCode(tt.err)
+		if (got == tt.err) != tt.unchanged {
+			t.Errorf("test %d: PromoteCode(%v): got unchanged %t, want %t", i+1, tt.err, got == tt.err, tt.unchanged)
+		}
+		if got == nil {
+			continue
+		}
+		if cErr, ok := got.(interface{ Code() int }); ok && cErr.Code() != tt.want {
+			t.Errorf("test %d: PromoteCode(%v).Code(): got %d, want %d", i+1, tt.err, cErr.Code(), tt.want)
+		}
+		if got.Error() != tt.err.Error() {
+			t.Errorf("test %d: PromoteCode(%v): got message %q, want %q", i+1, tt.err, got.Error(), tt.err.Error())
+		}
+	}
+
+	if code := generic.Code(); code != 500 {
+		t.Errorf("PromoteCode modified its argument: got code %d, want 500", code)
+	}
+}
+
+func TestCodeInRange(t *testing.T) {
+	tests := []struct {
+		err    error
+		lo, hi int
+		want   bool
+	}{
+		{nil, 400, 499, false},
+		{io.EOF, 400, 499, false},
+		{New("undefined"), -10, 10, false},
+		{New("x").SetCode(399), 400, 499, false},
+		{New("x").SetCode(400), 400, 499, true},
+		{New("x").SetCode(450), 400, 499, true},
+		{New("x").SetCode(499), 400, 499, true},
+		{New("x").SetCode(500), 400, 499, false},
+		{Wrap(New("x").SetCode(404), "wrapped"), 400, 499, true},
+		{New("x").SetCode(404), 499, 400, false},
+		{WithHTTPStatus(New("x").SetCode(404), 404), 400, 499, true},
+		{WithMessage(New("x").SetCode(404), "annotated"), 400, 499, true},
+		{Join(io.EOF, New("x").SetCode(404)), 400, 499, true},
+		{Join(io.EOF, New("x").SetCode(500)), 400, 499, false},
+	}
+
+	for i, tt := range tests {
+		got := CodeInRange(tt.err, tt.lo, tt.hi)
+		if got != tt.want {
+			t.Errorf("test %d: CodeInRange(%v, %d, %d): got %t, want %t", i+1, tt.err, tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+func TestCauseCode(t *testing.T) {
+	root := New("duplicate key").SetCode(409)
+
+	tests := []struct {
+		err           error
+		wantCode      int
+		wantCauseCode int
+	}{
+		{root, 409, ErrCodeNotDefined},
+		{Wrap(root, "insert"), 409, 409},
+		{Codef(500, root, "insert"), 500, 409},
+		{Wrap(Codef(500, root, "insert"), "handler"), 500, 500},
+		{WithMessage(io.EOF, "read"), ErrCodeNotDefined, ErrCodeNotDefined},
+		{WithHTTPStatus(Codef(503, io.EOF, "read"), 503), 503, ErrCodeNotDefined},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.(interface{ Code() int }).Code(); got != tt.wantCode {
+			t.Errorf("test %d: %v.Code(): got %d, want %d", i+1, tt.err, got, tt.wantCode)
+		}
+		if got := CauseCode(tt.err); got != tt.wantCauseCode {
+			t.Errorf("test %d: CauseCode(%v): got %d, want %d", i+1, tt.err, got, tt.wantCauseCode)
+		}
+	}
+}
+
+func TestGetCode(t *testing.T) {
+	inner := New("not found").SetCode(404)
+	reset := WithMessage(inner, "reset")
+	_ = reset.SetCode(ErrCodeNotDefined)
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ErrCodeNotDefined},
+		{io.EOF, ErrCodeNotDefined},
+		{WithStack(io.EOF), ErrCodeNotDefined},
+		{New("no code"), ErrCodeNotDefined},
+		{inner, 404},
+		{Wrap(Wrap(inner, "load"), "handle"), 404},
+		{Wrap(reset, "outer"), 404},
+		{Codef(500, reset, "outer"), 500},
+		{fmt.Errorf("wrapped: %w", WithHTTPStatus(inner, 404)), 404},
+	}
+
+	for i, tt := range tests {
+		if got := GetCode(tt.err); got != tt.want {
+			t.Errorf("test %d: GetCode(%v): got %d, want %d", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHasCode(t *testing.T) {
+	inner := New("not found").SetCode(404)
+
+	tests := []struct {
+		err  error
+		code int
+		want bool
+	}{
+		{nil, 404, false},
+		{io.EOF, ErrCodeNotDefined, false},
+		{New("no code"), ErrCodeNotDefined, false},
+		{inner, 404, true},
+		{Wrap(WithMessage(inner, "query"), "load"), 404, true},
+		{Codef(409, inner, "reclassified"), 404, true},
+		{Codef(409, inner, "reclassified"), 409, true},
+		{Wrap(inner, "load"), 500, false},
+		{Join(io.EOF, NewWithCode(404, "x")), 404, true},
+		{Wrap(Join(io.EOF, Wrap(inner, "load")), "batch"), 404, true},
+		{Join(io.EOF, NewWithCode(404, "x")), 409, false},
+	}
+
+	for i, tt := range tests {
+		if got := HasCode(tt.err, tt.code); got != tt.want {
+			t.Errorf("test %d: HasCode(%v, %d): got %t, want %t", i+1, tt.err, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCodeName(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{-1010, "TEST_NOT_FOUND"},
+		{ErrCodeOK, "OK"},
+		{ErrCodeFailed, "FAILED"},
+		{-1011, ""},
+		{ErrCodeNotDefined, ""},
+	}
+	for i, tt := range tests {
+		if got := CodeName(tt.code); got != tt.want {
+			t.Errorf("test %d: CodeName(%d): got %q, want %q", i+1, tt.code, got, tt.want)
+		}
+	}
+
+	root := New("row missing").WithCode(-1010)
+	root.stack = nil
+	err := WithMessage(root, "query").WithCode(-1011)
+	err.stack = nil
+	want := "[code=-1010 TEST_NOT_FOUND] row missing\n[code=-1011] query"
+	if got := fmt.Sprintf("%+v", err); got != want {
+		t.Errorf("%%+v: got %q, want %q", got, want)
+	}
+}
+
+var (
+	_ CodeError = (*MsgCodeErr)(nil)
+	_ CodeError = (*CauseMsgCodeError)(nil)
+	_ CodeError = (*StackError)(nil)
+)
+
+func TestIsCodeError(t *testing.T) {
+	root := New("root")
+	foreign := fmt.Errorf("foreign: %w", root)
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{nil, nil},
+		{io.EOF, nil},
+		{root, root},
+		{foreign, root},
+		{WithHTTPStatus(foreign, 404), root},
+	}
+	for i, tt := range tests {
+		got, ok := IsCodeError(tt.err)
+		if ok != (tt.want != nil) || (ok && got != tt.want) {
+			t.Errorf("test %d: IsCodeError(%v): got (%v, %t), want %v", i+1, tt.err, got, ok, tt.want)
+		}
+	}
+
+	err := Wrap(foreign, "outer")
+	cErr, ok := IsCodeError(err)
+	if !ok || cErr != CodeError(err) {
+		t.Fatalf("IsCodeError(%v): got (%v, %t), want the outermost layer", err, cErr, ok)
+	}
+	_ = cErr.SetCode(404)
+	if got := cErr.Code(); got != 404 {
+		t.Errorf("CodeError.SetCode(404): got code %d", got)
+	}
+}
+
+func TestSetCodeInheritance(t *testing.T) {
+	defer SetCodeInheritance(InheritCode)
+
+	inner := NewWithCode(404, "not found")
+	hidden := WithMessageCode(inner, ErrCodeNotDefined, "lookup")
+
+	tests := []struct {
+		mode CodeInheritance
+		wrap func() error
+		want int
+	}{
+		{InheritCode, func() error { return Wrap(inner, "outer") }, 404},
+		{InheritCode, func() error { return Wrapf(hidden, "outer %d", 1) }, ErrCodeNotDefined},
+		{InheritCode, func() error { return WithMessage(inner, "outer") }, 404},
+		{ClearCode, func() error { return Wrap(inner, "outer") }, ErrCodeNotDefined},
+		{ClearCode, func() error { return WithMessagef(inner, "outer %d", 1) }, ErrCodeNotDefined},
+		{ClearCode, func() error { return WrapWithCode(inner, 409, "outer") }, 409},
+		{DeepestCode, func() error { return Wrap(inner, "outer") }, 404},
+		{DeepestCode, func() error { return Wrapf(hidden, "outer %d", 1) }, 404},
+		{DeepestCode, func() error { return WithMessage(hidden, "outer") }, 404},
+		{DeepestCode, func() error { return Wrap(io.EOF, "outer") }, ErrCodeNotDefined},
+	}
+
+	for i, tt := range tests {
+		SetCodeInheritance(tt.mode)
+		err := tt.wrap()
+		if got := err.(interface{ Code() int }).Code(); got != tt.want {
+			t.Errorf("test %d: %v: Code(): got %d, want %d", i+1, err, got, tt.want)
+		}
+	}
+
+	SetCodeInheritance(ClearCode)
+	if got := CauseCode(Wrap(inner, "outer")); got != 404 {
+		t.Errorf("CauseCode with ClearCode: got %d, want 404", got)
+	}
+}
+
+func TestCategory(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{io.EOF, ""},
+		{New("no code"), ""},
+		{NewWithCode(91000, "bad token"), "auth"},
+		{NewWithCode(91999, "expired"), "auth"},
+		{Wrap(NewWithCode(92500, "deadlock"), "save user"), "db"},
+		{WithHTTPStatus(NewWithCode(92000, "timeout"), 503), "db"},
+		{NewWithCode(93000, "uncategorized"), ""},
+	}
+
+	for i, tt := range tests {
+		if got := Category(tt.err); got != tt.want {
+			t.Errorf("test %d: Category(%v): got %q, want %q", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	for _, r := range [][2]int{{91500, 91600}, {90000, 91000}, {92999, 93999}, {90000, 99999}, {94000, 93000}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterCategory(\"overlap\", %d, %d): expected panic", r[0], r[1])
+				}
+			}()
+			RegisterCategory("overlap", r[0], r[1])
+		}()
+	}
+}
+
+func TestCodeChain(t *testing.T) {
+	err := WrapWithCode(Wrap(NewWithCode(-8001, "timeout"), "query"), 503, "handle request")
+
+	if got, want := CodeChain(err), []int{503, 503, -8001, -8001, -8001}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeChain(%v): got %v, want %v", err, got, want)
+	}
+	if got, want := CodeNameChain(err), []string{"503", "503", "TEST_TIMEOUT", "TEST_TIMEOUT", "TEST_TIMEOUT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeNameChain(%v): got %v, want %v", err, got, want)
+	}
+
+	plain := WithHTTPStatus(Wrap(io.EOF, "read"), 500)
+	if got, want := CodeChain(plain), []int{ErrCodeNotDefined, ErrCodeNotDefined, ErrCodeNotDefined, ErrCodeNotDefined}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeChain(%v): got %v, want %v", plain, got, want)
+	}
+	if got, want := CodeNameChain(NewWithCode(ErrCodeOK, "ok")), []string{"OK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeNameChain(ok): got %v, want %v", got, want)
+	}
+
+	if got := CodeChain(nil); got != nil {
+		t.Errorf("CodeChain(nil): got %v, want nil", got)
+	}
+	if got := CodeNameChain(nil); got != nil {
+		t.Errorf("CodeNameChain(nil): got %v, want nil", got)
+	}
+}
@@ -0,0 +1,101 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A CountingCollector aggregates errors, keeping one instance of each
+// distinct error together with the number of times it was added. Errors
+// are considered the same when they have the same code and message; their
+// stack traces are ignored. This keeps reports of batch jobs, in which the
+// same failure can occur thousands of times, compact.
+//
+// The zero value is ready to use. A CountingCollector is safe for
+// concurrent use.
+type CountingCollector struct {
+	mu     sync.Mutex
+	counts []ErrorCount
+	index  map[string]int
+}
+
+// ErrorCount is a distinct error collected by a CountingCollector and the
+// number of times it was added.
+type ErrorCount struct {
+	Err   error
+	Count int
+}
+
+// fingerprint identifies errors which CountingCollector treats as the same.
+func fingerprint(err error) string {
+	code := ErrCodeNotDefined
+	if cErr, ok := err.(interface{ Code() int }); ok {
+		code = cErr.Code()
+	}
+	return strconv.Itoa(code) + ":" + err.Error()
+}
+
+// Add adds err to the collector. The first error with a given fingerprint
+// is kept; later ones only increase its count. Add ignores nil errors.
+func (c *CountingCollector) Add(err error) {
+	if err == nil {
+		return
+	}
+	fp := fingerprint(err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i, ok := c.index[fp]; ok {
+		c.counts[i].Count++
+		return
+	}
+	if c.index == nil {
+		c.index = make(map[string]int)
+	}
+	c.index[fp] = len(c.counts)
+	c.counts = append(c.counts, ErrorCount{Err: err, Count: 1})
+}
+
+// Summary returns each distinct error added to the collector with its
+// count, in the order they were first added.
+func (c *CountingCollector) Summary() []ErrorCount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ErrorCount(nil), c.counts...)
+}
+
+// Err returns an error aggregating the distinct errors added to the
+// collector, or nil if none were added. Its message lists the message of
+// each distinct error followed by its count, one per line, as in
+//
+//	connection refused (x1423)
+//	timeout (x2)
+//
+// and it implements Unwrap() []error, returning the distinct errors.
+func (c *CountingCollector) Err() error {
+	summary := c.Summary()
+	if len(summary) == 0 {
+		return nil
+	}
+	return countedErrors(summary)
+}
+
+// countedErrors is the error returned by CountingCollector.Err.
+type countedErrors []ErrorCount
+
+func (e countedErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, ec := range e {
+		lines[i] = ec.Err.Error() + " (x" + strconv.Itoa(ec.Count) + ")"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the distinct errors.
+func (e countedErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, ec := range e {
+		errs[i] = ec.Err
+	}
+	return errs
+}
@@ -0,0 +1,70 @@
+package errors
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestCountingCollector(t *testing.T) {
+	var c CountingCollector
+	if got := c.Err(); got != nil {
+		t.Errorf("empty CountingCollector.Err(): got %v, want nil", got)
+	}
+
+	first := New("connection refused").SetCode(503)
+	c.Add(first)
+	c.Add(nil)
+	c.Add(io.EOF)
+	for i := 0; i < 3; i++ {
+		c.Add(New("connection refused").SetCode(503))
+	}
+	c.Add(New("connection refused").SetCode(504))
+	c.Add(io.EOF)
+
+	summary := c.Summary()
+	want := []struct {
+		err   error
+		count int
+	}{
+		{first, 4},
+		{io.EOF, 2},
+		{nil, 1},
+	}
+	if len(summary) != len(want) {
+		t.Fatalf("CountingCollector.Summary(): got %d entries, want %d", len(summary), len(want))
+	}
+	for i, w := range want {
+		if summary[i].Count != w.count || (w.err != nil && summary[i].Err != w.err) {
+			t.Errorf("entry %d: got (%v, %d), want (%v, %d)", i, summary[i].Err, summary[i].Count, w.err, w.count)
+		}
+	}
+
+	err := c.Err()
+	wantMsg := "connection refused (x4)\nEOF (x2)\nconnection refused (x1)"
+	if err == nil || err.Error() != wantMsg {
+		t.Errorf("CountingCollector.Err(): got %v, want %q", err, wantMsg)
+	}
+	if errs := err.(interface{ Unwrap() []error }).Unwrap(); len(errs) != 3 || errs[0] != first {
+		t.Errorf("CountingCollector.Err().Unwrap(): got %v", errs)
+	}
+}
+
+func TestCountingCollectorConcurrent(t *testing.T) {
+	var c CountingCollector
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(io.EOF)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if summary := c.Summary(); len(summary) != 1 || summary[0].Count != 800 {
+		t.Errorf("CountingCollector.Summary(): got %v, want one entry counted 800 times", summary)
+	}
+}
@@ -0,0 +1,69 @@
+package errors
+
+import (
+	"context"
+	"sync"
+)
+
+// contextKeys holds the keys registered with RegisterContextKey.
+var contextKeys struct {
+	sync.RWMutex
+	keys []contextKey
+}
+
+type contextKey struct {
+	key   interface{}
+	field string
+}
+
+// RegisterContextKey makes WrapCtx copy the value stored in a context under
+// key into the field named field of the errors it creates. Keys are
+// compared as by context.Context.Value. It is safe for concurrent use, but
+// is usually called from init functions.
+func RegisterContextKey(key interface{}, field string) {
+	contextKeys.Lock()
+	defer contextKeys.Unlock()
+	contextKeys.keys = append(contextKeys.keys, contextKey{key, field})
+}
+
+// WrapCtx returns an error annotating err with a stack trace
+// at the point WrapCtx is called, and the supplied message, as Wrap does.
+// In addition, for every key registered with RegisterContextKey for which
+// ctx holds a non-nil value, the value is recorded in the Fields of the new
+// error under the registered field name. Keys missing from ctx are skipped,
+// and if ctx is nil WrapCtx behaves exactly like Wrap.
+//
+// The fields are recorded on the new, outermost layer, so they take
+// precedence over fields of the same name set on err or by PushScope.
+// If two registered keys map to the same field name, the one registered
+// last wins.
+// If err is nil, WrapCtx returns nil.
+func WrapCtx(ctx context.Context, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WrapCtx", message)
+
+	fields := scopeFields()
+	if ctx != nil {
+		contextKeys.RLock()
+		for _, k := range contextKeys.keys {
+			v := ctx.Value(k.key)
+			if v == nil {
+				continue
+			}
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			fields[k.field] = v
+		}
+		contextKeys.RUnlock()
+	}
+
+	cErr := annotate(err, message)
+	return &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: fields,
+	}
+}
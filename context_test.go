@@ -0,0 +1,45 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type ctxKey string
+
+func TestWrapCtx(t *testing.T) {
+	RegisterContextKey(ctxKey("request-id"), "requestID")
+	RegisterContextKey(ctxKey("user"), "userID")
+
+	ctx := context.WithValue(context.Background(), ctxKey("request-id"), "r1")
+	ctx = context.WithValue(ctx, ctxKey("unregistered"), "ignored")
+
+	if got := WrapCtx(ctx, nil, "no error"); got != nil {
+		t.Errorf("WrapCtx(ctx, nil, \"no error\"): got %#v, expected nil", got)
+	}
+
+	pop := PushScope(map[string]interface{}{"requestID": "scoped", "step": "load"})
+	err := WrapCtx(ctx, New("x").SetCode(404), "load user")
+	pop()
+
+	if got, want := err.Error(), "load user: x"; got != want {
+		t.Errorf("WrapCtx: got %q, want %q", got, want)
+	}
+	if got := err.(*StackError).Code(); got != 404 {
+		t.Errorf("WrapCtx: got code %d, want %d", got, 404)
+	}
+	if got, want := Fields(err), map[string]interface{}{"requestID": "r1", "step": "load"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(WrapCtx(...)): got %v, want %v", got, want)
+	}
+	if top := fmt.Sprintf("%n", err.(*StackError).StackTrace()[0]); top != "TestWrapCtx" {
+		t.Errorf("WrapCtx: got top frame %q, want %q", top, "TestWrapCtx")
+	}
+
+	var noCtx context.Context
+	if got := Fields(WrapCtx(noCtx, io.EOF, "read")); got != nil {
+		t.Errorf("Fields(WrapCtx(nil, ...)): got %v, want nil", got)
+	}
+}
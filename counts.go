@@ -0,0 +1,72 @@
+package errors
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// countByCode is non-zero when codes are being counted.
+var countByCode int32
+
+// codeCounts holds the counts returned by CodeCounts.
+var codeCounts = struct {
+	sync.Mutex
+	m map[int]int64
+}{m: make(map[int]int64)}
+
+func init() {
+	newHooks = append(newHooks, countNew)
+	codeHooks = append(codeHooks, countCode)
+}
+
+// SetCountByCode enables or disables counting errors by code. While
+// enabled, the package counts every code assigned to an error: codes set
+// with SetCode, codes supplied to functions such as NewCode and Codef, and
+// codes of errors created by the package with a code already defined. Codes
+// propagated by wrapping, as done by Wrap, are not counted again.
+//
+// Counting is disabled by default and costs almost nothing while disabled.
+// It gives a lightweight view of error rates by code; it complements, and
+// is no replacement for, a proper metrics library.
+func SetCountByCode(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&countByCode, v)
+}
+
+// CodeCounts returns a snapshot of the number of times each code has been
+// counted since the process started or since the last call to
+// ResetCodeCounts. It is safe for concurrent use.
+func CodeCounts() map[int]int64 {
+	codeCounts.Lock()
+	defer codeCounts.Unlock()
+	counts := make(map[int]int64, len(codeCounts.m))
+	for code, n := range codeCounts.m {
+		counts[code] = n
+	}
+	return counts
+}
+
+// ResetCodeCounts discards all counts. It is mostly useful in tests.
+func ResetCodeCounts() {
+	codeCounts.Lock()
+	defer codeCounts.Unlock()
+	codeCounts.m = make(map[int]int64)
+}
+
+func countNew(err error) {
+	if cErr, ok := err.(interface{ Code() int }); ok && cErr.Code() != ErrCodeNotDefined {
+		countCode(cErr.Code())
+	}
+}
+
+func countCode(code int) {
+	if atomic.LoadInt32(&countByCode) == 0 {
+		return
+	}
+	codeCounts.Lock()
+	codeCounts.m[code]++
+	codeCounts.Unlock()
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCodeCounts(t *testing.T) {
+	ResetCodeCounts()
+	New("not counted").SetCode(500)
+
+	SetCountByCode(true)
+	defer SetCountByCode(false)
+	defer ResetCodeCounts()
+
+	New("a").SetCode(404)
+	Wrap(New("b").SetCode(404), "propagated")
+	NewCode(409)
+	Codef(500, io.EOF, "read")
+	TrySetCode(io.EOF, 502)
+	New("no code")
+
+	want := map[int]int64{404: 2, 409: 1, 500: 1, 502: 1}
+	if got := CodeCounts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CodeCounts(): got %v, want %v", got, want)
+	}
+
+	ResetCodeCounts()
+	if got := CodeCounts(); len(got) != 0 {
+		t.Errorf("CodeCounts() after ResetCodeCounts: got %v, want empty", got)
+	}
+}
+
+func TestCodeCountsConcurrent(t *testing.T) {
+	SetCountByCode(true)
+	defer SetCountByCode(false)
+	defer ResetCodeCounts()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				NewCode(-4001)
+				CodeCounts()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := CodeCounts()[-4001]; got != 800 {
+		t.Errorf("CodeCounts()[-4001]: got %d, want %d", got, 800)
+	}
+}
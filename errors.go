@@ -95,44 +95,116 @@ package errors
 import (
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // New returns an error with the supplied message.
 // New also records the stack trace at the point it was called.
 func New(message string) *MsgCodeErr {
-	return &MsgCodeErr{
-		msg:   message,
-		code:  ErrCodeNotDefined,
-		stack: callers(),
+	err := &MsgCodeErr{
+		msg:    message,
+		code:   ErrCodeNotDefined,
+		fields: scopeFields(),
 	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
 }
 
 // Errorf formats according to a format specifier and returns the string
 // as a value that satisfies error.
 // Errorf also records the stack trace at the point it was called.
 func Errorf(format string, args ...interface{}) *MsgCodeErr {
-	return &MsgCodeErr{
-		msg:   fmt.Sprintf(format, args...),
-		stack: callers(),
+	err := &MsgCodeErr{
+		msg:    fmt.Sprintf(format, args...),
+		code:   ErrCodeNotDefined,
+		fields: scopeFields(),
+	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
+}
+
+// NewWithCode returns an error with the supplied code and message.
+// NewWithCode also records the stack trace at the point it was called.
+func NewWithCode(code int, message string) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:    message,
+		code:   int64(code),
+		fields: scopeFields(),
+	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
+}
+
+// NewLight returns an error with the supplied code and message, like
+// NewWithCode, but records no stack trace, which makes it much cheaper to
+// create. It suits sentinel errors and errors which are compared by code
+// rather than printed. The %+v verb prints a NewLight error as its code and
+// message alone, with no trace, and its StackTrace method returns nil.
+func NewLight(code int, message string) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:    message,
+		code:   int64(code),
+		fields: scopeFields(),
+	}
+	onNew(err)
+	return err
+}
+
+// ErrorfWithCode formats according to a format specifier and returns the
+// string as a value that satisfies error, with the supplied code.
+// ErrorfWithCode also records the stack trace at the point it was called.
+func ErrorfWithCode(code int, format string, args ...interface{}) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:    fmt.Sprintf(format, args...),
+		code:   int64(code),
+		fields: scopeFields(),
 	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
 }
 
 // MsgCodeErr is an error that has a message and a stack, but no caller.
+// It never wraps another error, so it is always the root of its chain.
+//
+// The code of a MsgCodeErr may be read and set concurrently from several
+// goroutines.
 type MsgCodeErr struct {
-	code int
+	// code is accessed atomically; it comes first to keep it 64-bit
+	// aligned on 32-bit platforms.
+	code int64
 	msg  string
 	*stack
+	fields    map[string]interface{}
+	expected  bool
+	temporary bool
 }
 
 // MsgCodeErr implements the error interface.
 func (f *MsgCodeErr) Error() string { return f.msg }
 
-// Format implements fmt.Formatter.
+// Message returns the message of the error.
+func (f *MsgCodeErr) Message() string { return f.msg }
+
+// Unwrap returns nil: a MsgCodeErr is always the root of its chain. It lets
+// generic chain walkers treat every error of this package alike.
+func (f *MsgCodeErr) Unwrap() error { return nil }
+
+// Format implements fmt.Formatter. The %d verb prints the error code.
 func (f *MsgCodeErr) Format(s fmt.State, verb rune) {
 	switch verb {
+	case 'd':
+		_, _ = io.WriteString(s, strconv.Itoa(f.Code()))
 	case 'v':
 		if s.Flag('+') {
-			_, _ = io.WriteString(s, f.msg)
+			_, _ = io.WriteString(s, codePrefix(f.Code(), nil)+f.msg)
 			f.stack.Format(s, verb)
 			return
 		}
@@ -145,29 +217,191 @@ func (f *MsgCodeErr) Format(s fmt.State, verb rune) {
 }
 
 // Code returns the error code.
-func (f *MsgCodeErr) Code() int { return f.code }
+func (f *MsgCodeErr) Code() int { return int(atomic.LoadInt64(&f.code)) }
+
+// StackTrace returns the stack trace recorded when the error was created.
+// It is empty if none was recorded.
+func (f *MsgCodeErr) StackTrace() StackTrace { return f.stack.StackTrace() }
 
 // SetCode sets the error code.
 func (f *MsgCodeErr) SetCode(code int) error {
-	f.code = code
+	return f.WithCode(code)
+}
+
+// WithCode sets the error code and returns f, so that calls can be chained.
+func (f *MsgCodeErr) WithCode(code int) *MsgCodeErr {
+	atomic.StoreInt64(&f.code, int64(code))
+	onCode(code)
 	return f
 }
 
+// Clone returns a copy of f with the same message, code, fields and stack
+// trace. Changing the code of the copy does not affect f.
+//
+// SetCode and WithCode modify the error they are called on. Calling them on
+// a sentinel error stored in a package-level variable changes the sentinel
+// for every user of it; call them on a Clone of the sentinel instead.
+// If f is nil, Clone returns nil.
+func (f *MsgCodeErr) Clone() *MsgCodeErr {
+	if f == nil {
+		return nil
+	}
+	return &MsgCodeErr{
+		code:      atomic.LoadInt64(&f.code),
+		msg:       f.msg,
+		stack:     f.stack,
+		fields:    cloneFields(f.fields),
+		expected:  f.expected,
+		temporary: f.temporary,
+	}
+}
+
+// cloneFields returns a copy of fields, or nil if fields is empty.
+func cloneFields(fields map[string]interface{}) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	c := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		c[k] = v
+	}
+	return c
+}
+
+// Clone returns a copy of the outermost layer of err, if it is a
+// *MsgCodeErr, *StackError or *CauseMsgCodeError, so that its code can be
+// changed without affecting err. Only the layers needed for that are
+// copied: a *StackError, whose code is that of the error it wraps, is
+// copied along with that error, but otherwise the copy wraps the same
+// cause as err. Errors of other types are returned unchanged.
+// If err is nil, Clone returns nil.
+func Clone(err error) error {
+	switch e := err.(type) {
+	case *MsgCodeErr:
+		if e != nil {
+			return e.Clone()
+		}
+	case *StackError:
+		if e != nil {
+			c := *e
+			c.error = Clone(e.error)
+			c.fields = cloneFields(e.fields)
+			return &c
+		}
+	case *CauseMsgCodeError:
+		if e != nil {
+			return &CauseMsgCodeError{
+				code:      atomic.LoadInt64(&e.code),
+				cause:     e.cause,
+				causeCode: e.causeCode,
+				msg:       e.message(),
+				stack:     e.stack,
+			}
+		}
+	}
+	return err
+}
+
+// Is reports whether f matches target, for use by Is. target matches if it
+// is a *MsgCodeErr with the same code or, if target has no code, the same
+// message. This lets a sentinel created with New and SetCode match every
+// error carrying its code, however deeply wrapped.
+func (f *MsgCodeErr) Is(target error) bool {
+	t, ok := target.(*MsgCodeErr)
+	if !ok || t == nil {
+		return false
+	}
+	if code := t.Code(); code != ErrCodeNotDefined {
+		return f.Code() == code
+	}
+	return f.msg == t.msg
+}
+
+// matchesCode reports whether target is a *MsgCodeErr with a defined code
+// equal to code.
+func matchesCode(code int, target error) bool {
+	t, ok := target.(*MsgCodeErr)
+	if !ok || t == nil || code == ErrCodeNotDefined {
+		return false
+	}
+	return t.Code() == code
+}
+
 // WithStack annotates err with a stack trace at the point WithStack was called.
+// It always adds a new trace, even if err already has one; use NewStack to
+// avoid recording a second one.
 // If err is nil, WithStack returns nil.
 func WithStack(err error) *StackError {
 	if err == nil {
 		return nil
 	}
-	return &StackError{
-		err,
-		callers(),
+	w := &StackError{
+		error:     err,
+		fields:    scopeFields(),
+		stackOnly: true,
 	}
+	w.stack = callers(w)
+	return w
+}
+
+// WithStackSkip annotates err with a stack trace recorded like WithStack,
+// but with skip frames omitted from its top, so that helpers which wrap
+// errors on behalf of their callers can attribute the trace to them. A
+// skip of 0 is equivalent to WithStack, 1 starts the trace at the caller of
+// the function calling WithStackSkip, and so on. A negative skip is
+// treated as 0.
+// If err is nil, WithStackSkip returns nil.
+func WithStackSkip(err error, skip int) *StackError {
+	if err == nil {
+		return nil
+	}
+	if skip < 0 {
+		skip = 0
+	}
+	w := &StackError{
+		error:     err,
+		fields:    scopeFields(),
+		stackOnly: true,
+	}
+	w.stack = callersSkip(w, skip)
+	return w
+}
+
+// NewStack annotates err with a stack trace at the point NewStack was
+// called, like WithStack, unless an error in err's chain already carries a
+// stack trace, as the errors created by New, Wrap and the other
+// constructors of this package do. In that case it returns err unchanged,
+// so that %+v does not print the same frames twice. NewStack is therefore
+// idempotent: NewStack(NewStack(err)) records at most one trace.
+// If err is nil, NewStack returns nil.
+func NewStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	if GetStackTrace(err) != nil {
+		return err
+	}
+	w := &StackError{
+		error:     err,
+		fields:    scopeFields(),
+		stackOnly: true,
+	}
+	w.stack = callers(w)
+	return w
 }
 
 type StackError struct {
 	error
 	*stack
+	fields map[string]interface{}
+
+	// stackOnly records that the StackError was created by WithStack and
+	// so adds nothing to the error but a stack trace.
+	stackOnly bool
+
+	// loc is the "file:line: " prefix added to the message by WrapLoc,
+	// kept so that SetMessage can preserve it.
+	loc string
 }
 
 // Cause returns the underlying cause of the error
@@ -176,13 +410,33 @@ func (w *StackError) Cause() error { return w.error }
 // Unwrap provides compatibility for Go 1.13 error chains.
 func (w *StackError) Unwrap() error { return w.error }
 
-// Format implements fmt.Formatter.
+// StackTrace returns the stack trace recorded when the error was created.
+// It is empty if none was recorded.
+func (w *StackError) StackTrace() StackTrace { return w.stack.StackTrace() }
+
+// Is reports whether the code of w matches that of target, a *MsgCodeErr
+// with a defined code, and otherwise forwards Is to the cause of the error.
+func (w *StackError) Is(target error) bool {
+	if matchesCode(w.Code(), target) {
+		return true
+	}
+	if err, ok := w.error.(interface{ Is(error) bool }); ok {
+		return err.Is(target)
+	}
+	return false
+}
+
+// Format implements fmt.Formatter. The %d verb prints the error code.
 func (w *StackError) Format(s fmt.State, verb rune) {
 	switch verb {
+	case 'd':
+		_, _ = io.WriteString(s, strconv.Itoa(w.Code()))
 	case 'v':
 		if s.Flag('+') {
-			_, _ = fmt.Fprintf(s, "%+v", w.Cause())
-			w.stack.Format(s, verb)
+			formatCause(s, w.error)
+			if !foreignTrace(w.error) {
+				w.stack.Format(s, verb)
+			}
 			return
 		}
 		fallthrough
@@ -198,11 +452,18 @@ func (w *StackError) Code() int {
 	if err, ok := w.error.(interface{ Code() int }); ok {
 		return err.Code()
 	}
-	return 0
+	return ErrCodeNotDefined
 }
 
 // SetCode sets the error code, if defined.
 func (w *StackError) SetCode(code int) error {
+	return w.WithCode(code)
+}
+
+// WithCode sets the error code, if defined, and returns w, so that calls
+// can be chained. The code is set on the wrapped error; it is not set if
+// the wrapped error cannot carry a code.
+func (w *StackError) WithCode(code int) *StackError {
 	if err, ok := w.error.(interface{ SetCode(int) error }); ok {
 		_ = err.SetCode(code)
 	}
@@ -216,19 +477,35 @@ func Wrap(err error, message string) *StackError {
 	if err == nil {
 		return nil
 	}
+	checkMessage("Wrap", message)
 
-	errCode := ErrCodeNotDefined
-	if cErr, ok := err.(interface{ Code() int }); ok {
-		errCode = cErr.Code()
+	err = annotate(err, message)
+	return &StackError{
+		error:  err,
+		stack:  callers(err),
+		fields: scopeFields(),
+	}
+}
+
+// WrapSkip returns an error annotating err with a stack trace and the
+// supplied message, like Wrap, but with skip frames omitted from the top of
+// the trace, as described for WithStackSkip. A negative skip is treated
+// as 0.
+// If err is nil, WrapSkip returns nil.
+func WrapSkip(err error, skip int, message string) *StackError {
+	if err == nil {
+		return nil
 	}
-	err = &CauseMsgCodeError{
-		cause: err,
-		msg:   message,
-		code:  errCode,
+	checkMessage("WrapSkip", message)
+	if skip < 0 {
+		skip = 0
 	}
+
+	err = annotate(err, message)
 	return &StackError{
-		err,
-		callers(),
+		error:  err,
+		stack:  callersSkip(err, skip),
+		fields: scopeFields(),
 	}
 }
 
@@ -239,68 +516,438 @@ func Wrapf(err error, format string, args ...interface{}) *StackError {
 	if err == nil {
 		return nil
 	}
+	message := fmt.Sprintf(format, args...)
+	checkMessage("Wrapf", message)
 
-	errCode := ErrCodeNotDefined
-	if cErr, ok := err.(interface{ Code() int }); ok {
-		errCode = cErr.Code()
+	err = annotate(err, message)
+	return &StackError{
+		error:  err,
+		stack:  callers(err),
+		fields: scopeFields(),
 	}
-	err = &CauseMsgCodeError{
-		cause: err,
-		msg:   fmt.Sprintf(format, args...),
-		code:  errCode,
+}
+
+// Codef returns an error annotating err with a stack trace
+// at the point Codef is called, the format specifier and the supplied code.
+// The code replaces any code carried by err.
+// If err is nil, Codef returns nil.
+func Codef(code int, err error, format string, args ...interface{}) *StackError {
+	if err == nil {
+		return nil
 	}
+	message := fmt.Sprintf(format, args...)
+	checkMessage("Codef", message)
+
+	onCode(code)
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
 	return &StackError{
-		err,
-		callers(),
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
 	}
 }
 
-// WithMessage annotates err with a new message.
-// If err is nil, WithMessage returns nil.
-func WithMessage(err error, message string) *CauseMsgCodeError {
+// WrapWithCode returns an error annotating err with a stack trace
+// at the point WrapWithCode is called, the supplied message and code.
+// The code replaces any code carried by err.
+// If err is nil, WrapWithCode returns nil.
+func WrapWithCode(err error, code int, message string) *StackError {
 	if err == nil {
 		return nil
 	}
+	checkMessage("WrapWithCode", message)
 
-	errCode := ErrCodeNotDefined
-	if cErr, ok := err.(interface{ Code() int }); ok {
-		errCode = cErr.Code()
+	onCode(code)
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
+	return &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
 	}
+}
 
-	return &CauseMsgCodeError{
-		cause: err,
-		msg:   message,
-		code:  errCode,
+// WrapfWithCode returns an error annotating err with a stack trace
+// at the point WrapfWithCode is called, the format specifier and the
+// supplied code. The code replaces any code carried by err.
+// If err is nil, WrapfWithCode returns nil.
+func WrapfWithCode(err error, code int, format string, args ...interface{}) *StackError {
+	if err == nil {
+		return nil
+	}
+	message := fmt.Sprintf(format, args...)
+	checkMessage("WrapfWithCode", message)
+
+	onCode(code)
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
+	return &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
+	}
+}
+
+// WrapIf returns nil if err is nil, and otherwise an error annotating err
+// with a stack trace at the point WrapIf is called and the supplied message,
+// exactly as Wrap does. It signals that err may be nil, so that
+//
+//	return errors.WrapIf(db.Close(), "close database")
+//
+// replaces an explicit nil check.
+func WrapIf(err error, message string) *StackError {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WrapIf", message)
+
+	cErr := annotate(err, message)
+	return &StackError{
+		error:  cErr,
+		stack:  callersSkip(cErr, 0),
+		fields: scopeFields(),
 	}
 }
 
+// WrapIfCode returns nil if err is nil, and otherwise an error annotating
+// err with a stack trace at the point WrapIfCode is called, the supplied
+// message and the supplied code, exactly as WrapWithCode does.
+func WrapIfCode(err error, code int, message string) *StackError {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WrapIfCode", message)
+
+	onCode(code)
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
+	return &StackError{
+		error:  cErr,
+		stack:  callersSkip(cErr, 0),
+		fields: scopeFields(),
+	}
+}
+
+// WrapDedup returns an error annotating err with a stack trace at the point
+// WrapDedup is called and the supplied message, as Wrap does, unless the
+// outermost message in err's chain, as returned by Message, is already
+// message. In that case err is returned unchanged, so that wrapping an
+// error with the same message on every iteration of a retry loop yields
+// "read failed: EOF" rather than "read failed: read failed: EOF". Only
+// adjacent duplicates are collapsed: a message repeated further down the
+// chain, below a different one, is added again.
+// If err is nil, WrapDedup returns nil.
+func WrapDedup(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	if Message(err) == message {
+		return err
+	}
+	checkMessage("WrapDedup", message)
+
+	cErr := annotate(err, message)
+	return &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
+	}
+}
+
+// WrapAll converts err into an error of this package with the supplied
+// code, annotated with a stack trace at the point WrapAll is called and the
+// supplied message. If err is a foreign error, one which does not implement
+// CodeError, it is replaced by a *MsgCodeErr with its message and code, so
+// that the chain has a proper root; err itself is no longer part of the
+// chain, so Is and As do not find it. Otherwise err is kept and WrapAll
+// behaves like WrapWithCode.
+// If err is nil, WrapAll returns nil.
+func WrapAll(err error, code int, message string) *StackError {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WrapAll", message)
+
+	if _, ok := err.(CodeError); !ok {
+		leaf := &MsgCodeErr{
+			msg:    err.Error(),
+			code:   int64(code),
+			fields: scopeFields(),
+		}
+		onNew(leaf)
+		err = leaf
+	} else {
+		onCode(code)
+	}
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
+	return &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
+	}
+}
+
+// WrapLoc returns an error annotating err with a stack trace
+// at the point WrapLoc is called, and the supplied message prefixed with the
+// file name and line number of the call, as in
+//
+//	query.go:42: message: cause
+//
+// This records the location in the result of Error, for log pipelines which
+// do not print stack traces.
+// If err is nil, WrapLoc returns nil.
+func WrapLoc(err error, message string) error {
+	return wrapLoc(err, 0, "WrapLoc", message)
+}
+
+// WrapLocSkip returns an error annotating err like WrapLoc, but with skip
+// frames omitted from the top of the stack trace, as described for
+// WithStackSkip, and the location taken from the first remaining frame.
+// Helpers which wrap errors for their callers pass a skip of 1 to report
+// the location of the call to the helper. A negative skip is treated as 0.
+// If err is nil, WrapLocSkip returns nil.
+func WrapLocSkip(err error, skip int, message string) error {
+	if skip < 0 {
+		skip = 0
+	}
+	return wrapLoc(err, skip, "WrapLocSkip", message)
+}
+
+// wrapLoc implements WrapLoc and WrapLocSkip. It must be called directly
+// by them, as the skip counts the frames above their caller.
+func wrapLoc(err error, skip int, fn, message string) error {
+	if err == nil {
+		return nil
+	}
+	checkMessage(fn, message)
+
+	st := callersSkip(nil, skip+1)
+	var loc string
+	if st != nil && len(*st) > 0 {
+		loc = fmt.Sprintf("%v: ", Frame((*st)[0]))
+	}
+	err = annotate(err, loc+message)
+	return &StackError{
+		error:  err,
+		stack:  st,
+		fields: scopeFields(),
+		loc:    loc,
+	}
+}
+
+// WithMessage annotates err with a new message.
+// WithMessage also records the stack trace at the point it was called.
+// If err is nil, WithMessage returns nil.
+func WithMessage(err error, message string) *CauseMsgCodeError {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WithMessage", message)
+
+	cErr := annotate(err, message)
+	cErr.stack = callers(cErr)
+	return cErr
+}
+
 // WithMessagef annotates err with the format specifier.
+// WithMessagef also records the stack trace at the point it was called.
 // If err is nil, WithMessagef returns nil.
 func WithMessagef(err error, format string, args ...interface{}) *CauseMsgCodeError {
 	if err == nil {
 		return nil
 	}
+	message := fmt.Sprintf(format, args...)
+	checkMessage("WithMessagef", message)
 
-	errCode := ErrCodeNotDefined
-	if cErr, ok := err.(interface{ Code() int }); ok {
-		errCode = cErr.Code()
+	cErr := annotate(err, message)
+	cErr.stack = callers(cErr)
+	return cErr
+}
+
+// WithMessageCode annotates err with a new message and the supplied code,
+// which replaces any code carried by err. Unlike WithMessage, it does not
+// record a stack trace, making it suitable for hot paths.
+// If err is nil, WithMessageCode returns nil.
+func WithMessageCode(err error, code int, message string) *CauseMsgCodeError {
+	if err == nil {
+		return nil
 	}
+	checkMessage("WithMessageCode", message)
 
-	return &CauseMsgCodeError{
-		cause: err,
-		msg:   fmt.Sprintf(format, args...),
-		code:  errCode,
+	onCode(code)
+	cErr := annotate(err, message)
+	cErr.code = int64(code)
+	return cErr
+}
+
+// SetMessage replaces the message added by the outermost layer of err, so
+// that a message can be refined without adding another layer. If err is a
+// *MsgCodeErr or a *CauseMsgCodeError, or a *StackError created by Wrap or
+// a similar function, its message is changed in place and err is returned;
+// the location prefix added by WrapLoc is kept. Otherwise err is annotated
+// with message and a stack trace at the point SetMessage is called, as
+// WithMessage does, and the new error is returned.
+// If err is nil, SetMessage returns nil.
+//
+// Because the message is changed in place, SetMessage must not be called on
+// an error which other goroutines may be reading, nor on a sentinel error
+// stored in a package-level variable, which would change the sentinel for
+// every user of it; call it on a Clone of the error instead.
+func SetMessage(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	checkMessage("SetMessage", message)
+
+	switch e := err.(type) {
+	case *MsgCodeErr:
+		e.msg = message
+		return e
+	case *CauseMsgCodeError:
+		e.msg, e.lazy = message, nil
+		return e
+	case *StackError:
+		if cErr, ok := e.error.(*CauseMsgCodeError); ok && !e.stackOnly {
+			cErr.msg, cErr.lazy = e.loc+message, nil
+			return e
+		}
+	}
+	cErr := annotate(err, message)
+	cErr.stack = callers(cErr)
+	return cErr
+}
+
+// WithMessageLazy annotates err with the message returned by fn, like
+// WithMessage, but fn is only called when the message is first needed, by
+// Error, Format or another function reading it, and its result is kept for
+// later uses. Annotating errors which are usually handled without being
+// printed then costs no formatting. fn is called at most once, and must be
+// safe to call from whichever goroutine first reads the message.
+// WithMessageLazy also records the stack trace at the point it was called.
+// If err is nil, WithMessageLazy returns nil and fn is never called.
+func WithMessageLazy(err error, fn func() string) *CauseMsgCodeError {
+	if err == nil {
+		return nil
+	}
+
+	cErr := annotate(err, "")
+	cErr.lazy = &lazyMessage{fn: fn}
+	cErr.stack = callers(cErr)
+	return cErr
+}
+
+// causeSep holds the string placed between a message and its cause.
+var causeSep atomic.Value
+
+// SetCauseSeparator makes the Error method of errors returned by this
+// package separate each message from its cause with sep, rendering for
+// example "outer | inner | root" for SetCauseSeparator(" | ").
+// SetCauseSeparator("") restores the default separator, ": ". Only the
+// result of Error is affected; the %+v verb still prints each message on its
+// own line.
+//
+// SetCauseSeparator affects all errors, including existing ones. It should
+// be called during program initialisation.
+func SetCauseSeparator(sep string) {
+	if sep == "" {
+		sep = ": "
+	}
+	causeSep.Store(sep)
+}
+
+// SetCauseArrow makes the Error method of errors returned by this package
+// separate each message from its cause with arrow surrounded by spaces,
+// rendering for example "outer -> inner -> root" for SetCauseArrow("->").
+// This distinguishes the causal structure from colons inside messages.
+// SetCauseArrow("") restores the default separator, ": ".
+//
+// SetCauseArrow is shorthand for SetCauseSeparator(" " + arrow + " ").
+func SetCauseArrow(arrow string) {
+	if arrow == "" {
+		SetCauseSeparator("")
+		return
+	}
+	SetCauseSeparator(" " + arrow + " ")
+}
+
+// causeSeparator returns the string placed between a message and its cause.
+func causeSeparator() string {
+	if sep, ok := causeSep.Load().(string); ok {
+		return sep
 	}
+	return ": "
 }
 
+// CauseMsgCodeError is an error that annotates its cause with a message and
+// a code. Its code may be read and set concurrently from several goroutines.
 type CauseMsgCodeError struct {
-	cause error
-	code  int
-	msg   string
+	// code is accessed atomically; it comes first to keep it 64-bit
+	// aligned on 32-bit platforms.
+	code      int64
+	cause     error
+	causeCode int
+	msg       string
+
+	// stack is recorded by WithMessage and WithMessagef; it is nil for
+	// the layers created by Wrap and its relatives, whose StackError
+	// records the stack instead.
+	*stack
+
+	// lazy, if not nil, computes msg the first time it is needed; see
+	// WithMessageLazy.
+	lazy *lazyMessage
+}
+
+// lazyMessage is a message computed on first use.
+type lazyMessage struct {
+	once sync.Once
+	fn   func() string
+}
+
+// message returns the message added by the error, computing it first if
+// it was supplied to WithMessageLazy.
+func (w *CauseMsgCodeError) message() string {
+	if l := w.lazy; l != nil {
+		l.once.Do(func() {
+			w.msg = l.fn()
+			l.fn = nil
+		})
+	}
+	return w.msg
+}
+
+// annotate returns a CauseMsgCodeError wrapping err with message, whose
+// code is derived from err as configured by SetCodeInheritance.
+func annotate(err error, message string) *CauseMsgCodeError {
+	causeCode := ErrCodeNotDefined
+	if cErr, ok := err.(interface{ Code() int }); ok {
+		causeCode = cErr.Code()
+	}
+	code := causeCode
+	switch CodeInheritance(atomic.LoadInt32(&codeInheritance)) {
+	case ClearCode:
+		code = ErrCodeNotDefined
+	case DeepestCode:
+		code = deepestCode(err)
+	}
+	return &CauseMsgCodeError{
+		cause:     err,
+		msg:       message,
+		code:      int64(code),
+		causeCode: causeCode,
+	}
 }
 
 // MsgCodeErr implements the error interface.
-func (w *CauseMsgCodeError) Error() string { return w.msg + ": " + w.cause.Error() }
+func (w *CauseMsgCodeError) Error() string {
+	return w.message() + causeSeparator() + w.cause.Error()
+}
+
+// Message returns the message added by the error, without that of its
+// cause.
+func (w *CauseMsgCodeError) Message() string { return w.message() }
 
 // Cause returns the underlying cause of the error.
 func (w *CauseMsgCodeError) Cause() error { return w.cause }
@@ -308,13 +955,36 @@ func (w *CauseMsgCodeError) Cause() error { return w.cause }
 // Unwrap provides compatibility for Go 1.13 error chains.
 func (w *CauseMsgCodeError) Unwrap() error { return w.cause }
 
-// Format implements fmt.Formatter.
+// StackTrace returns the stack trace recorded by WithMessage or
+// WithMessagef. It is empty for errors created by other functions.
+func (w *CauseMsgCodeError) StackTrace() StackTrace { return w.stack.StackTrace() }
+
+// Is reports whether the code of w matches that of target, a *MsgCodeErr
+// with a defined code, so that a layer reclassified by WrapWithCode or a
+// similar function matches the sentinel of its new code. Otherwise it
+// forwards Is to the cause of the error.
+func (w *CauseMsgCodeError) Is(target error) bool {
+	if matchesCode(w.Code(), target) {
+		return true
+	}
+	if err, ok := w.cause.(interface{ Is(error) bool }); ok {
+		return err.Is(target)
+	}
+	return false
+}
+
+// Format implements fmt.Formatter. The %d verb prints the error code.
 func (w *CauseMsgCodeError) Format(s fmt.State, verb rune) {
 	switch verb {
+	case 'd':
+		_, _ = io.WriteString(s, strconv.Itoa(w.Code()))
 	case 'v':
 		if s.Flag('+') {
-			_, _ = fmt.Fprintf(s, "%+v\n", w.Cause())
-			_, _ = io.WriteString(s, w.msg)
+			formatCause(s, w.cause)
+			_, _ = io.WriteString(s, "\n"+codePrefix(w.Code(), w.cause)+w.message())
+			if !foreignTrace(w.cause) {
+				w.stack.Format(s, verb)
+			}
 			return
 		}
 		fallthrough
@@ -323,12 +993,73 @@ func (w *CauseMsgCodeError) Format(s fmt.State, verb rune) {
 	}
 }
 
+// formatCause writes cause as the %+v verb prints it, for the errors of
+// this package which print their causes first. The contract for chains
+// which mix the errors of this package with foreign ones is that:
+//
+//   - each error of this package prints its message, code and stack trace
+//     after its cause;
+//   - the first foreign error reached is formatted once, with its own %+v,
+//     and trailing newlines are removed from its output so that the layers
+//     above it always start on a line of their own;
+//   - if that foreign error carries its own stack trace, as the errors of
+//     github.com/pkg/errors do, it is the only trace printed: the layers
+//     above it print their messages and codes but not their traces.
+func formatCause(s fmt.State, cause error) {
+	if isOwn(cause) {
+		_, _ = fmt.Fprintf(s, "%+v", cause)
+		return
+	}
+	_, _ = io.WriteString(s, strings.TrimRight(fmt.Sprintf("%+v", cause), "\n"))
+}
+
+// foreignTrace reports whether the first foreign error in err's chain, as
+// returned by Root, has a StackTrace method, in which case formatCause
+// printed its trace.
+func foreignTrace(err error) bool {
+	root := Root(err)
+	if root == nil {
+		return false
+	}
+	m, ok := reflect.TypeOf(root).MethodByName("StackTrace")
+	return ok && m.Type.NumIn() == 1 && m.Type.NumOut() == 1
+}
+
+// codePrefix returns the "[code=N] " written by %+v before the message of
+// a layer with the given code and cause, or "[code=N NAME] " if the code
+// was registered with RegisterCode. It returns "" if the code is not
+// defined or, when SetCollapseCodes is enabled, equals the code of cause.
+func codePrefix(code int, cause error) string {
+	if code == ErrCodeNotDefined {
+		return ""
+	}
+	if atomic.LoadInt32(&collapseCodes) != 0 {
+		if cErr, ok := cause.(interface{ Code() int }); ok && cErr.Code() == code {
+			return ""
+		}
+	}
+	if name := CodeName(code); name != "" {
+		return "[code=" + strconv.Itoa(code) + " " + name + "] "
+	}
+	return "[code=" + strconv.Itoa(code) + "] "
+}
+
 // Code returns the error code.
-func (w *CauseMsgCodeError) Code() int { return w.code }
+func (w *CauseMsgCodeError) Code() int { return int(atomic.LoadInt64(&w.code)) }
+
+// CauseCode returns the code the cause had when it was wrapped, which
+// differs from Code if this layer reclassified the error.
+func (w *CauseMsgCodeError) CauseCode() int { return w.causeCode }
 
 // SetCode sets the error code.
 func (w *CauseMsgCodeError) SetCode(code int) error {
-	w.code = code
+	return w.WithCode(code)
+}
+
+// WithCode sets the error code and returns w, so that calls can be chained.
+func (w *CauseMsgCodeError) WithCode(code int) *CauseMsgCodeError {
+	atomic.StoreInt64(&w.code, int64(code))
+	onCode(code)
 	return w
 }
 
@@ -343,17 +1074,42 @@ func (w *CauseMsgCodeError) SetCode(code int) error {
 // If the error does not implement Cause, the original error will
 // be returned. If the error is nil, nil will be returned without further
 // investigation.
+//
+// An error which wraps several errors by implementing
+//
+//	interface {
+//	        Unwrap() []error
+//	}
+//
+// such as those returned by Join and the standard library's Join, has no
+// single cause, so Cause returns it when it is reached. Use GetCode or Find
+// to search its branches.
+//
+// If the chain is cyclic, Cause returns the last error reached before an
+// error already visited would be repeated. At most maxDepth (100) links are
+// followed, so Cause always returns.
 func Cause(err error) error {
 	type causer interface {
 		Cause() error
 	}
 
-	for err != nil {
+	var buf [8]error
+	visited := buf[:0]
+	for i := 0; err != nil && i < maxDepth; i++ {
 		cause, ok := err.(causer)
 		if !ok {
 			break
 		}
-		err = cause.Cause()
+		if reflect.TypeOf(err).Comparable() {
+			visited = append(visited, err)
+		}
+		next := cause.Cause()
+		for _, v := range visited {
+			if reflect.TypeOf(next) == reflect.TypeOf(v) && next == v {
+				return err
+			}
+		}
+		err = next
 	}
 	return err
 }
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -132,6 +134,36 @@ func TestWrapf(t *testing.T) {
 	}
 }
 
+func TestCodefNil(t *testing.T) {
+	got := Codef(404, nil, "no error")
+	if got != nil {
+		t.Errorf("Codef(404, nil, \"no error\"): got %#v, expected nil", got)
+	}
+}
+
+func TestCodef(t *testing.T) {
+	tests := []struct {
+		code     int
+		err      error
+		want     string
+		wantCode int
+	}{
+		{404, io.EOF, "read error 1: EOF", 404},
+		{409, New("dup").SetCode(500), "read error 1: dup", 409},
+		{ErrCodeOK, Wrap(New("dup").SetCode(500), "insert"), "read error 1: insert: dup", ErrCodeOK},
+	}
+
+	for _, tt := range tests {
+		got := Codef(tt.code, tt.err, "read error %d", 1)
+		if got.Error() != tt.want {
+			t.Errorf("Codef(%d, %v): got: %q, want %q", tt.code, tt.err, got.Error(), tt.want)
+		}
+		if got.Code() != tt.wantCode {
+			t.Errorf("Codef(%d, %v).Code(): got: %d, want %d", tt.code, tt.err, got.Code(), tt.wantCode)
+		}
+	}
+}
+
 func TestErrorf(t *testing.T) {
 	tests := []struct {
 		err  error
@@ -249,3 +281,758 @@ func TestErrorEquality(t *testing.T) {
 		}
 	}
 }
+
+func TestSetCauseArrow(t *testing.T) {
+	err := Wrap(WithMessage(New("root: detail"), "inner"), "outer")
+
+	SetCauseArrow("->")
+	got := err.Error()
+	SetCauseArrow("")
+	if want := "outer -> inner -> root: detail"; got != want {
+		t.Errorf("SetCauseArrow(\"->\"): got %q, want %q", got, want)
+	}
+
+	if got, want := err.Error(), "outer: inner: root: detail"; got != want {
+		t.Errorf("SetCauseArrow(\"\"): got %q, want %q", got, want)
+	}
+}
+
+func TestWrapLoc(t *testing.T) {
+	if got := WrapLoc(nil, "no error"); got != nil {
+		t.Errorf("WrapLoc(nil, \"no error\"): got %#v, expected nil", got)
+	}
+
+	err, line := WrapLoc(New("x").SetCode(404), "query"), caller().line()
+	want := fmt.Sprintf("errors_test.go:%d: query: x", line)
+	if got := err.Error(); got != want {
+		t.Errorf("WrapLoc: got %q, want %q", got, want)
+	}
+	if got := err.(*StackError).Code(); got != 404 {
+		t.Errorf("WrapLoc: got code %d, want %d", got, 404)
+	}
+}
+
+func TestNewWithCode(t *testing.T) {
+	tests := []struct {
+		err      *MsgCodeErr
+		wantCode int
+		wantMsg  string
+	}{
+		{NewWithCode(404, "not found"), 404, "not found"},
+		{NewWithCode(ErrCodeOK, ""), ErrCodeOK, ""},
+		{ErrorfWithCode(409, "user %d exists", 42), 409, "user 42 exists"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.Code(); got != tt.wantCode {
+			t.Errorf("test %d: Code(): got %d, want %d", i+1, got, tt.wantCode)
+		}
+		if got := tt.err.Error(); got != tt.wantMsg {
+			t.Errorf("test %d: Error(): got %q, want %q", i+1, got, tt.wantMsg)
+		}
+		st := tt.err.StackTrace()
+		if len(st) == 0 || !strings.HasSuffix(st[0].name(), ".TestNewWithCode") {
+			t.Errorf("test %d: stack trace does not start at the caller: %v", i+1, st)
+		}
+	}
+}
+
+func TestWrapWithCode(t *testing.T) {
+	dbErr := NewWithCode(1062, "duplicate entry")
+
+	tests := []struct {
+		err       *StackError
+		wantCode  int
+		wantMsg   string
+		wantCause int
+	}{
+		{WrapWithCode(dbErr, 409, "create user"), 409, "create user: duplicate entry", 1062},
+		{WrapfWithCode(dbErr, 409, "create user %d", 42), 409, "create user 42: duplicate entry", 1062},
+		{WrapWithCode(io.EOF, 500, "read"), 500, "read: EOF", ErrCodeNotDefined},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.Code(); got != tt.wantCode {
+			t.Errorf("test %d: Code(): got %d, want %d", i+1, got, tt.wantCode)
+		}
+		if got := tt.err.Error(); got != tt.wantMsg {
+			t.Errorf("test %d: Error(): got %q, want %q", i+1, got, tt.wantMsg)
+		}
+		if got := CauseCode(tt.err); got != tt.wantCause {
+			t.Errorf("test %d: CauseCode(): got %d, want %d", i+1, got, tt.wantCause)
+		}
+		st := tt.err.StackTrace()
+		if len(st) == 0 || !strings.HasSuffix(st[0].name(), ".TestWrapWithCode") {
+			t.Errorf("test %d: stack trace does not start at the caller: %v", i+1, st)
+		}
+	}
+	if dbErr.Code() != 1062 {
+		t.Errorf("WrapWithCode modified the code of its cause: got %d, want 1062", dbErr.Code())
+	}
+
+	if got := WrapWithCode(nil, 409, "no error"); got != nil {
+		t.Errorf("WrapWithCode(nil): got %#v, expected nil", got)
+	}
+	if got := WrapfWithCode(nil, 409, "no error"); got != nil {
+		t.Errorf("WrapfWithCode(nil): got %#v, expected nil", got)
+	}
+}
+
+func TestErrorfCode(t *testing.T) {
+	if got := Errorf("read %s", "config").Code(); got != ErrCodeNotDefined {
+		t.Errorf("Errorf(...).Code(): got %d, want %d", got, ErrCodeNotDefined)
+	}
+}
+
+func TestNewErrorfAgree(t *testing.T) {
+	n, f := New("boom"), Errorf("%s", "boom")
+	if n.Code() != f.Code() {
+		t.Errorf("New and Errorf disagree on the default code: got %d and %d", n.Code(), f.Code())
+	}
+	if n.Error() != f.Error() {
+		t.Errorf("New and Errorf disagree on the message: got %q and %q", n.Error(), f.Error())
+	}
+}
+
+func TestStackErrorCodeNotDefined(t *testing.T) {
+	tests := []error{
+		fmt.Errorf("plain"),
+		io.EOF,
+		WithStack(fmt.Errorf("plain")),
+	}
+
+	for i, err := range tests {
+		if got := WithStack(err).Code(); got != ErrCodeNotDefined {
+			t.Errorf("test %d: WithStack(%v).Code(): got %d, want %d", i+1, err, got, ErrCodeNotDefined)
+		}
+	}
+}
+
+func TestWithMessageStack(t *testing.T) {
+	tests := []*CauseMsgCodeError{
+		WithMessage(io.EOF, "read"),
+		WithMessagef(io.EOF, "read %s", "config"),
+	}
+
+	for i, err := range tests {
+		st := err.StackTrace()
+		if len(st) == 0 || !strings.HasSuffix(st[0].name(), ".TestWithMessageStack") {
+			t.Errorf("test %d: stack trace does not start at the caller: %v", i+1, st)
+		}
+		if got := fmt.Sprintf("%+v", err); !strings.Contains(got, "errors.TestWithMessageStack\n") {
+			t.Errorf("test %d: %%+v does not print the caller's frame:\n%s", i+1, got)
+		}
+	}
+
+	if st := Wrap(io.EOF, "read").Cause().(*CauseMsgCodeError).StackTrace(); len(st) != 0 {
+		t.Errorf("Wrap recorded a second stack trace on its message layer: %v", st)
+	}
+}
+
+func TestGetStackTrace(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{Expected("miss"), false},
+		{New("boom"), true},
+		{Errorf("boom %d", 1), true},
+		{WithStack(io.EOF), true},
+		{WithMessage(io.EOF, "read"), true},
+		{WithHTTPStatus(Wrap(io.EOF, "read"), 500), true},
+		{WithHTTPStatus(Expected("miss"), 404), false},
+	}
+
+	for i, tt := range tests {
+		st := GetStackTrace(tt.err)
+		if !tt.want {
+			if st != nil {
+				t.Errorf("test %d: GetStackTrace(%v): got %v, want nil", i+1, tt.err, st)
+			}
+			continue
+		}
+		if len(st) == 0 || st[0].name() != "github.com/WeiquanWa/errors.TestGetStackTrace" {
+			t.Errorf("test %d: GetStackTrace(%v): top frame is not the construction site: %v", i+1, tt.err, st)
+		}
+	}
+}
+
+func TestSetTrimStackPaths(t *testing.T) {
+	defer SetTrimStackPaths(nil)
+
+	var inner *MsgCodeErr
+	Find(New("outer"), func(error) bool {
+		inner = New("inner")
+		return true
+	})
+	for _, f := range inner.StackTrace() {
+		if file := f.file(); strings.HasPrefix(file, pkgDir) && !strings.HasSuffix(file, "_test.go") {
+			t.Errorf("stack trace contains a frame of the package itself: %+v", f)
+		}
+	}
+	if top := inner.StackTrace()[0]; !strings.HasSuffix(top.file(), "errors_test.go") {
+		t.Errorf("top frame: got %+v, want a frame of errors_test.go", top)
+	}
+
+	err := New("trimmed")
+	SetTrimStackPaths([]string{pkgDir, runtime.GOROOT()})
+	st := err.StackTrace()
+	if len(st) != 0 {
+		t.Errorf("SetTrimStackPaths: got frames %v, want none", st)
+	}
+	if got := fmt.Sprintf("%+v", err); got != "trimmed" {
+		t.Errorf("SetTrimStackPaths: got %%+v %q, want %q", got, "trimmed")
+	}
+
+	SetTrimStackPaths(nil)
+	if len(err.StackTrace()) == 0 {
+		t.Errorf("SetTrimStackPaths(nil): got no frames")
+	}
+}
+
+func TestWithCode(t *testing.T) {
+	m := New("x").WithCode(404)
+	if m.Code() != 404 {
+		t.Errorf("MsgCodeErr.WithCode(404): got code %d", m.Code())
+	}
+	if ws := WithStack(m).WithCode(410); ws.Code() != 410 || m.Code() != 410 {
+		t.Errorf("StackError.WithCode(410): got code %d, cause code %d", ws.Code(), m.Code())
+	}
+	if ws := WithStack(io.EOF).WithCode(500); ws.Code() != ErrCodeNotDefined {
+		t.Errorf("StackError.WithCode on an uncoded cause: got code %d, want %d", ws.Code(), ErrCodeNotDefined)
+	}
+	c := WithMessage(m, "outer").WithCode(409)
+	if c.Code() != 409 || c.Cause() != error(m) {
+		t.Errorf("CauseMsgCodeError.WithCode(409): got code %d, cause %v", c.Code(), c.Cause())
+	}
+
+	if got := New("y").SetCode(7); got.(*MsgCodeErr).Code() != 7 {
+		t.Errorf("MsgCodeErr.SetCode(7): got code %d", got.(*MsgCodeErr).Code())
+	}
+}
+
+func TestCodeConcurrent(t *testing.T) {
+	sentinel := New("shared")
+	wrapped := WithMessage(sentinel, "wrapped")
+	errs := []interface {
+		Code() int
+		SetCode(int) error
+	}{sentinel, wrapped, WithStack(sentinel)}
+
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			for j := 0; j < 1000; j++ {
+				err := errs[j%len(errs)]
+				if i%2 == 0 {
+					_ = err.SetCode(j)
+				} else {
+					_ = err.Code()
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
+
+//go:noinline
+func skipHelper(err error, skip int) []*StackError {
+	return []*StackError{WithStackSkip(err, skip), WrapSkip(err, skip, "helper")}
+}
+
+func TestStackSkip(t *testing.T) {
+	tests := []struct {
+		skip int
+		want string
+	}{
+		{-1, "errors.skipHelper"},
+		{0, "errors.skipHelper"},
+		{1, "errors.TestStackSkip"},
+		{2, "testing.tRunner"},
+	}
+
+	for i, tt := range tests {
+		for _, err := range skipHelper(io.EOF, tt.skip) {
+			st := err.StackTrace()
+			if len(st) == 0 || !strings.HasSuffix(st[0].name(), tt.want) {
+				t.Errorf("test %d: skip %d: got top frame %v, want %s", i+1, tt.skip, st, tt.want)
+			}
+		}
+	}
+
+	if WithStackSkip(nil, 1) != nil || WrapSkip(nil, 1, "x") != nil {
+		t.Errorf("WithStackSkip and WrapSkip must return nil for a nil error")
+	}
+}
+
+func TestSetCauseSeparator(t *testing.T) {
+	root := New("root")
+	root.stack = nil
+	err := WithMessage(WithMessage(root, "inner"), "outer")
+	err.stack = nil
+	err.cause.(*CauseMsgCodeError).stack = nil
+
+	SetCauseSeparator(" | ")
+	got, formatted := err.Error(), fmt.Sprintf("%+v", err)
+	SetCauseSeparator("")
+
+	if want := "outer | inner | root"; got != want {
+		t.Errorf("SetCauseSeparator(\" | \"): got %q, want %q", got, want)
+	}
+	if want := "root\ninner\nouter"; formatted != want {
+		t.Errorf("SetCauseSeparator(\" | \"): got %%+v %q, want %q", formatted, want)
+	}
+	if got, want := err.Error(), "outer: inner: root"; got != want {
+		t.Errorf("SetCauseSeparator(\"\"): got %q, want %q", got, want)
+	}
+}
+
+func TestWrapAll(t *testing.T) {
+	if got := WrapAll(nil, 404, "no error"); got != nil {
+		t.Errorf("WrapAll(nil): got %#v, expected nil", got)
+	}
+
+	err := WrapAll(io.EOF, 503, "read config")
+	if got := err.Error(); got != "read config: EOF" {
+		t.Errorf("WrapAll(io.EOF): got %q, want %q", got, "read config: EOF")
+	}
+	leaf, ok := Cause(err).(*MsgCodeErr)
+	if !ok || leaf.Code() != 503 || leaf.Error() != "EOF" {
+		t.Errorf("WrapAll(io.EOF): got root %#v, want a *MsgCodeErr with code 503", Cause(err))
+	}
+	if got := GetCode(err); got != 503 {
+		t.Errorf("GetCode(WrapAll(io.EOF)): got %d, want 503", got)
+	}
+	if st := err.StackTrace(); len(st) == 0 || !strings.HasSuffix(st[0].name(), ".TestWrapAll") {
+		t.Errorf("WrapAll: stack trace does not start at the caller: %v", st)
+	}
+
+	root := New("row missing").WithCode(404)
+	err = WrapAll(root, 500, "load user")
+	if Cause(err) != error(root) || err.Code() != 500 || root.Code() != 404 {
+		t.Errorf("WrapAll(MsgCodeErr): got root %v and code %d, want the original root and code 500", Cause(err), err.Code())
+	}
+}
+
+func TestTraceStrings(t *testing.T) {
+	err := Wrap(io.EOF, "read")
+	got := TraceStrings(err)
+	if len(got) != len(err.StackTrace()) {
+		t.Errorf("TraceStrings: got %d entries, want %d", len(got), len(err.StackTrace()))
+	}
+	if len(got) == 0 || !strings.HasPrefix(got[0], "github.com/WeiquanWa/errors.TestTraceStrings\n\t") ||
+		!strings.Contains(got[0], "errors_test.go:") {
+		t.Errorf("TraceStrings: got first entry %q, want the caller's frame", got)
+	}
+
+	for _, err := range []error{nil, io.EOF, Expected("miss")} {
+		if got := TraceStrings(err); got == nil || len(got) != 0 {
+			t.Errorf("TraceStrings(%v): got %#v, want an empty slice", err, got)
+		}
+	}
+}
+
+func TestWithMessageCode(t *testing.T) {
+	dbErr := NewWithCode(1062, "duplicate entry")
+
+	tests := []struct {
+		err       *CauseMsgCodeError
+		wantCode  int
+		wantMsg   string
+		wantCause int
+	}{
+		{WithMessageCode(dbErr, 409, "create user"), 409, "create user: duplicate entry", 1062},
+		{WithMessageCode(io.EOF, 500, "read"), 500, "read: EOF", ErrCodeNotDefined},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.Code(); got != tt.wantCode {
+			t.Errorf("test %d: Code(): got %d, want %d", i+1, got, tt.wantCode)
+		}
+		if got := tt.err.Error(); got != tt.wantMsg {
+			t.Errorf("test %d: Error(): got %q, want %q", i+1, got, tt.wantMsg)
+		}
+		if got := tt.err.CauseCode(); got != tt.wantCause {
+			t.Errorf("test %d: CauseCode(): got %d, want %d", i+1, got, tt.wantCause)
+		}
+		if st := tt.err.StackTrace(); len(st) != 0 {
+			t.Errorf("test %d: got stack trace %v, want none", i+1, st)
+		}
+	}
+	if dbErr.Code() != 1062 {
+		t.Errorf("WithMessageCode modified the code of its cause: got %d, want 1062", dbErr.Code())
+	}
+
+	if got := WithMessageCode(nil, 409, "no error"); got != nil {
+		t.Errorf("WithMessageCode(nil): got %#v, expected nil", got)
+	}
+}
+
+func TestFormatCode(t *testing.T) {
+	coded, _ := TrySetCode(io.EOF, 418)
+
+	tests := []struct {
+		err    error
+		format string
+		want   string
+	}{
+		{New("plain"), "%d", "-1"},
+		{New("not found").SetCode(404), "%d", "404"},
+		{NewWithCode(409, "conflict"), "%s (%d)", "conflict (409)"},
+		{Wrap(NewWithCode(409, "conflict"), "create user"), "%d", "409"},
+		{WrapWithCode(io.EOF, 500, "read"), "%s (%d)", "read: EOF (500)"},
+		{WithMessage(NewWithCode(409, "conflict"), "create user"), "%d", "409"},
+		{WithMessageCode(io.EOF, 503, "read"), "%v [%d]", "read: EOF [503]"},
+		{WithField(NewWithCode(409, "conflict"), "user", 7), "%d", "409"},
+		{WithHTTPStatus(Wrap(NewWithCode(404, "missing"), "find"), 404), "%s (%d)", "find: missing (404)"},
+		{WithSeverity(WithMessage(NewWithCode(409, "conflict"), "create"), SeverityWarn), "%d", "409"},
+		{WithFields(io.EOF, map[string]interface{}{"k": 1}), "%d", "-1"},
+		{coded, "%v (%d)", "EOF (418)"},
+	}
+
+	for i, tt := range tests {
+		args := make([]interface{}, strings.Count(tt.format, "%"))
+		for j := range args {
+			args[j] = tt.err
+		}
+		if got := fmt.Sprintf(tt.format, args...); got != tt.want {
+			t.Errorf("test %d: Sprintf(%q): got %q, want %q", i+1, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	sentinel := New("not found").WithCode(404)
+
+	c := sentinel.Clone()
+	c.WithCode(410)
+	if sentinel.Code() != 404 {
+		t.Errorf("Clone: SetCode on the clone changed the sentinel's code to %d", sentinel.Code())
+	}
+	if c.Error() != sentinel.Error() || c.Code() != 410 {
+		t.Errorf("Clone: got %q with code %d, want %q with code 410", c.Error(), c.Code(), sentinel.Error())
+	}
+	if !reflect.DeepEqual(c.StackTrace(), sentinel.StackTrace()) {
+		t.Errorf("Clone: got stack trace %v, want %v", c.StackTrace(), sentinel.StackTrace())
+	}
+	if got := (*MsgCodeErr)(nil).Clone(); got != nil {
+		t.Errorf("nil Clone(): got %#v, want nil", got)
+	}
+
+	tests := []error{
+		sentinel,
+		WithStack(sentinel),
+		Wrap(sentinel, "lookup"),
+		WithMessage(sentinel, "lookup"),
+	}
+
+	for i, err := range tests {
+		c := Clone(err)
+		if c == err {
+			t.Errorf("test %d: Clone(%v): got the same error", i+1, err)
+		}
+		if c.Error() != err.Error() {
+			t.Errorf("test %d: Clone(%v): got message %q", i+1, err, c.Error())
+		}
+		before := err.(interface{ Code() int }).Code()
+		_ = c.(interface{ SetCode(int) error }).SetCode(500)
+		if got := err.(interface{ Code() int }).Code(); got != before {
+			t.Errorf("test %d: SetCode on Clone(%v) changed the original's code from %d to %d", i+1, err, before, got)
+		}
+		if got := c.(interface{ Code() int }).Code(); got != 500 {
+			t.Errorf("test %d: Clone(%v).Code(): got %d, want 500", i+1, err, got)
+		}
+	}
+	if sentinel.Code() != 404 {
+		t.Errorf("Clone: the sentinel's code changed to %d", sentinel.Code())
+	}
+
+	if got := Clone(io.EOF); got != io.EOF {
+		t.Errorf("Clone(io.EOF): got %#v, want io.EOF", got)
+	}
+	if got := Clone(nil); got != nil {
+		t.Errorf("Clone(nil): got %#v, want nil", got)
+	}
+}
+
+func TestCompactTrace(t *testing.T) {
+	err := Wrap(io.EOF, "read")
+	st := err.StackTrace()
+	got := CompactTrace(err)
+	if strings.ContainsAny(got, "\n\t") {
+		t.Errorf("CompactTrace: got %q, want a single line", got)
+	}
+	frames := strings.Split(got, " < ")
+	if len(frames) != len(st) {
+		t.Errorf("CompactTrace: got %d frames, want %d: %q", len(frames), len(st), got)
+	}
+	for i, f := range frames {
+		if i < len(st) && f != fmt.Sprintf("%v", st[i]) {
+			t.Errorf("CompactTrace: frame %d: got %q, want %q", i, f, fmt.Sprintf("%v", st[i]))
+		}
+	}
+	if !strings.HasPrefix(got, "errors_test.go:") {
+		t.Errorf("CompactTrace: got %q, want the caller's frame first", got)
+	}
+
+	for _, err := range []error{nil, io.EOF, Expected("miss")} {
+		if got := CompactTrace(err); got != "" {
+			t.Errorf("CompactTrace(%v): got %q, want \"\"", err, got)
+		}
+	}
+}
+
+func TestWrapIf(t *testing.T) {
+	if got := WrapIf(nil, "close"); got != nil {
+		t.Errorf("WrapIf(nil): got %#v, expected nil", got)
+	}
+	if got := WrapIfCode(nil, 500, "close"); got != nil {
+		t.Errorf("WrapIfCode(nil): got %#v, expected nil", got)
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	errs := []*StackError{WrapIf(io.EOF, "close"), WrapIfCode(New("busy").SetCode(409), 503, "close")}
+	for i, err := range errs {
+		if got := err.Error(); !strings.HasPrefix(got, "close: ") {
+			t.Errorf("test %d: Error(): got %q, want prefix %q", i+1, got, "close: ")
+		}
+		f, ok := err.StackTrace().TopFrame()
+		if !ok || f.Function() != "github.com/WeiquanWa/errors.TestWrapIf" || f.Line() != line+1 {
+			t.Errorf("test %d: got top frame %+v, want TestWrapIf at line %d", i+1, f, line+1)
+		}
+	}
+	if got := errs[0].Code(); got != ErrCodeNotDefined {
+		t.Errorf("WrapIf(io.EOF).Code(): got %d, want %d", got, ErrCodeNotDefined)
+	}
+	if got := errs[1].Code(); got != 503 {
+		t.Errorf("WrapIfCode(...).Code(): got %d, want 503", got)
+	}
+	if got := CauseCode(errs[1]); got != 409 {
+		t.Errorf("CauseCode(WrapIfCode(...)): got %d, want 409", got)
+	}
+}
+
+func TestWrapDedup(t *testing.T) {
+	if got := WrapDedup(nil, "read failed"); got != nil {
+		t.Errorf("WrapDedup(nil): got %#v, expected nil", got)
+	}
+
+	first := WrapDedup(io.EOF, "read failed")
+	err := first
+	for i := 0; i < 2; i++ {
+		err = WrapDedup(err, "read failed")
+	}
+	if err != first {
+		t.Errorf("WrapDedup: got %#v, want the first wrap unchanged", err)
+	}
+	if got, want := err.Error(), "read failed: EOF"; got != want {
+		t.Errorf("WrapDedup: got %q, want %q", got, want)
+	}
+	if got := ChainLen(err); got != 3 {
+		t.Errorf("WrapDedup: got chain length %d, want 3", got)
+	}
+	if st := GetStackTrace(err); len(st) == 0 || st[0].Function() != "github.com/WeiquanWa/errors.TestWrapDedup" {
+		t.Errorf("WrapDedup: got stack trace %v, want one starting at the caller", st)
+	}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{WrapDedup(WithStack(first), "read failed"), "read failed: EOF"},
+		{WrapDedup(WithHTTPStatus(first, 503), "read failed"), "read failed: EOF"},
+		{WrapDedup(first, "retry"), "retry: read failed: EOF"},
+		{WrapDedup(Wrap(first, "retry"), "read failed"), "read failed: retry: read failed: EOF"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("test %d: got %q, want %q", i+1, got, tt.want)
+		}
+	}
+}
+
+func TestSetMessage(t *testing.T) {
+	if got := SetMessage(nil, "msg"); got != nil {
+		t.Errorf("SetMessage(nil): got %#v, expected nil", got)
+	}
+
+	leaf := New("draft")
+	wrapped := Wrap(leaf, "draft wrap")
+	annotated := WithMessage(leaf, "draft message")
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{leaf, "final"},
+		{wrapped, "final wrap: final"},
+		{annotated, "final message: final"},
+	}
+	msgs := []string{"final", "final wrap", "final message"}
+
+	for i, tt := range tests {
+		got := SetMessage(tt.err, msgs[i])
+		if got != tt.err {
+			t.Errorf("test %d: SetMessage(%v): got %#v, want the error changed in place", i+1, tt.err, got)
+		}
+		if got.Error() != tt.want {
+			t.Errorf("test %d: SetMessage: got %q, want %q", i+1, got.Error(), tt.want)
+		}
+		if ChainLen(got) != ChainLen(tt.err) {
+			t.Errorf("test %d: SetMessage added a layer", i+1)
+		}
+	}
+
+	for i, err := range []error{io.EOF, WithStack(io.EOF), WithHTTPStatus(io.EOF, 503)} {
+		got := SetMessage(err, "read")
+		if got == err {
+			t.Errorf("fallback %d: SetMessage(%v): got the error unchanged, want a new layer", i+1, err)
+			continue
+		}
+		if want := "read: EOF"; got.Error() != want {
+			t.Errorf("fallback %d: SetMessage: got %q, want %q", i+1, got.Error(), want)
+		}
+		if Cause(got) != io.EOF || err.Error() != "EOF" {
+			t.Errorf("fallback %d: SetMessage changed the wrapped error", i+1)
+		}
+		if f, ok := OriginFrame(got); !ok || f.Function() != "github.com/WeiquanWa/errors.TestSetMessage" {
+			t.Errorf("fallback %d: got origin frame %+v, want TestSetMessage", i+1, f)
+		}
+	}
+
+	located := WrapLoc(io.EOF, "draft")
+	loc := strings.TrimSuffix(located.Error(), "draft: EOF")
+	if !strings.HasPrefix(loc, "errors_test.go:") {
+		t.Fatalf("WrapLoc: got %q, want an errors_test.go location prefix", located.Error())
+	}
+	if got := SetMessage(located, "read"); got != located || got.Error() != loc+"read: EOF" {
+		t.Errorf("SetMessage(WrapLoc): got %q, want %q changed in place", got.Error(), loc+"read: EOF")
+	}
+}
+
+func TestWithMessageLazy(t *testing.T) {
+	if got := WithMessageLazy(nil, func() string { panic("called") }); got != nil {
+		t.Errorf("WithMessageLazy(nil): got %#v, expected nil", got)
+	}
+
+	calls := 0
+	err := WithMessageLazy(NewWithCode(404, "not found"), func() string {
+		calls++
+		return fmt.Sprintf("lookup user %d", 42)
+	})
+	if calls != 0 {
+		t.Errorf("WithMessageLazy: fn called %d times before the message was used", calls)
+	}
+	if got := err.Code(); got != 404 || calls != 0 {
+		t.Errorf("WithMessageLazy: Code(): got %d after %d calls, want 404 after 0", got, calls)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got, want := err.Error(), "lookup user 42: not found"; got != want {
+			t.Errorf("WithMessageLazy: Error(): got %q, want %q", got, want)
+		}
+	}
+	if got := fmt.Sprintf("%+v", err); !strings.Contains(got, "lookup user 42\n") {
+		t.Errorf("WithMessageLazy: %%+v: got %q, want the message", got)
+	}
+	if got := Message(err); got != "lookup user 42" {
+		t.Errorf("Message(WithMessageLazy): got %q, want %q", got, "lookup user 42")
+	}
+	if calls != 1 {
+		t.Errorf("WithMessageLazy: fn called %d times, want 1", calls)
+	}
+	if f, ok := err.StackTrace().TopFrame(); !ok || f.Function() != "github.com/WeiquanWa/errors.TestWithMessageLazy" {
+		t.Errorf("WithMessageLazy: got top frame %+v, want TestWithMessageLazy", f)
+	}
+}
+
+func TestNewLight(t *testing.T) {
+	err := NewLight(404, "not found")
+	if err.stack != nil || err.StackTrace() != nil {
+		t.Errorf("NewLight: got stack trace %v, want none", err.StackTrace())
+	}
+	if got := err.Code(); got != 404 {
+		t.Errorf("NewLight: Code(): got %d, want 404", got)
+	}
+
+	tests := []struct {
+		err    error
+		format string
+		want   string
+	}{
+		{err, "%s", "not found"},
+		{err, "%v", "not found"},
+		{err, "%+v", "[code=404] not found"},
+		{err, "%d", "404"},
+		{WithMessage(err, "lookup"), "%v", "lookup: not found"},
+	}
+
+	for i, tt := range tests {
+		if got := fmt.Sprintf(tt.format, tt.err); got != tt.want {
+			t.Errorf("test %d: Sprintf(%q): got %q, want %q", i+1, tt.format, got, tt.want)
+		}
+	}
+	if !Is(Wrap(NewWithCode(404, "other"), "wrapped"), err) {
+		t.Errorf("Is(..., NewLight(404)): got false, want true")
+	}
+}
+
+func TestWithMessageLazySampled(t *testing.T) {
+	SetStackSampleRate(2)
+	defer SetStackSampleRate(0)
+
+	calls := 0
+	cause := NewLight(404, "not found")
+	var errs []*CauseMsgCodeError
+	for i := 0; i < 4; i++ {
+		errs = append(errs, WithMessageLazy(cause, func() string {
+			calls++
+			return "lookup user"
+		}))
+	}
+	if calls != 0 {
+		t.Errorf("WithMessageLazy with sampling: fn called %d times before the message was used", calls)
+	}
+	traced := 0
+	for _, err := range errs {
+		if len(err.StackTrace()) > 0 {
+			traced++
+		}
+	}
+	if traced != 2 {
+		t.Errorf("WithMessageLazy with sampling: got %d stack traces, want 2", traced)
+	}
+	if got := errs[0].Error(); got != "lookup user: not found" || calls != 1 {
+		t.Errorf("WithMessageLazy with sampling: got %q after %d calls", got, calls)
+	}
+}
+
+// wrapLocHelper wraps err for its caller, reporting the caller's location.
+func wrapLocHelper(err error) error {
+	return WrapLocSkip(err, 1, "helper")
+}
+
+func TestWrapLocSkip(t *testing.T) {
+	if got := WrapLocSkip(nil, 1, "no error"); got != nil {
+		t.Errorf("WrapLocSkip(nil, 1, \"no error\"): got %#v, expected nil", got)
+	}
+
+	err, line := wrapLocHelper(io.EOF), caller().line()
+	if want := fmt.Sprintf("errors_test.go:%d: helper: EOF", line); err.Error() != want {
+		t.Errorf("WrapLocSkip from a helper: got %q, want %q", err.Error(), want)
+	}
+	if top := fmt.Sprintf("%n", err.(*StackError).StackTrace()[0]); top != "TestWrapLocSkip" {
+		t.Errorf("WrapLocSkip from a helper: got top frame %q, want %q", top, "TestWrapLocSkip")
+	}
+
+	err, line = WrapLocSkip(io.EOF, -1, "direct"), caller().line()
+	if want := fmt.Sprintf("errors_test.go:%d: direct: EOF", line); err.Error() != want {
+		t.Errorf("WrapLocSkip with a negative skip: got %q, want %q", err.Error(), want)
+	}
+}
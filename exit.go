@@ -0,0 +1,36 @@
+package errors
+
+// WithExitCode annotates err with the exit status a command-line program
+// should terminate with when it fails because of err, so that main can do
+//
+//	os.Exit(errors.ExitCode(run()))
+//
+// The exit code is distinct from the error code reported by Code, which it
+// does not change.
+// If err is nil, WithExitCode returns nil.
+func WithExitCode(err error, exit int) error {
+	if err == nil {
+		return nil
+	}
+	return &withExitCode{annotation{err}, exit}
+}
+
+type withExitCode struct {
+	annotation
+	exit int
+}
+
+// ExitCode returns the exit code attached to err by WithExitCode. The
+// outermost exit code in the chain wins. If no exit code was attached,
+// ExitCode returns 1. If err is nil, ExitCode returns 0.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if w, ok := err.(*withExitCode); ok {
+			return w.exit
+		}
+	}
+	return 1
+}
@@ -0,0 +1,37 @@
+package errors
+
+import (
+	"io"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	usage := WithExitCode(NewWithCode(400, "unknown flag"), 2)
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{io.EOF, 1},
+		{New("failed"), 1},
+		{usage, 2},
+		{Wrap(usage, "parse arguments"), 2},
+		{WithMessage(Wrap(usage, "parse arguments"), "run"), 2},
+		{WithExitCode(Wrap(usage, "parse arguments"), 64), 64},
+		{WithExitCode(io.EOF, 0), 0},
+	}
+
+	for i, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("test %d: ExitCode(%v): got %d, want %d", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	if got := GetCode(Wrap(usage, "parse arguments")); got != 400 {
+		t.Errorf("GetCode(Wrap(usage)): got %d, want 400", got)
+	}
+	if got := WithExitCode(nil, 2); got != nil {
+		t.Errorf("WithExitCode(nil, 2): got %#v, expected nil", got)
+	}
+}
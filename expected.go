@@ -0,0 +1,50 @@
+package errors
+
+// Expected returns an error with the supplied message for failures which
+// are part of normal control flow, such as a cache miss. Unlike New, it
+// does not record a stack trace, and IsExpected reports true for it so
+// that monitoring hooks can skip alerting on it.
+//
+// Expected errors are ordinary error values: they can be compared with Is,
+// extracted with As, wrapped, and given a code with SetCode.
+func Expected(message string) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:      message,
+		code:     ErrCodeNotDefined,
+		fields:   scopeFields(),
+		expected: true,
+	}
+	onNew(err)
+	return err
+}
+
+// WithExpected marks err as expected, so that IsExpected reports true for
+// it and any error wrapping it. It does not change err's message or code.
+// If err is nil, WithExpected returns nil.
+func WithExpected(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &withExpected{annotation{err}}
+}
+
+type withExpected struct {
+	annotation
+}
+
+// IsExpected reports whether any error in err's chain was created by
+// Expected or marked with WithExpected.
+func IsExpected(err error) bool {
+	for i := 0; err != nil && i < maxDepth; i++ {
+		switch e := err.(type) {
+		case *withExpected:
+			return true
+		case *MsgCodeErr:
+			if e.expected {
+				return true
+			}
+		}
+		err = next(err)
+	}
+	return false
+}
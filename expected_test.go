@@ -0,0 +1,44 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestExpected(t *testing.T) {
+	miss := Expected("cache miss")
+	if miss.StackTrace() != nil {
+		t.Errorf("Expected: got stack trace %v, want none", miss.StackTrace())
+	}
+	if got := fmt.Sprintf("%+v", miss); got != "cache miss" {
+		t.Errorf("Expected: got %%+v %q, want %q", got, "cache miss")
+	}
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{New("boom"), false},
+		{miss, true},
+		{Wrap(miss, "lookup user"), true},
+		{Expected("not found").SetCode(404), true},
+		{WithExpected(io.EOF), true},
+		{Wrap(WithExpected(io.EOF), "read"), true},
+	}
+
+	for i, tt := range tests {
+		if got := IsExpected(tt.err); got != tt.want {
+			t.Errorf("test %d: IsExpected(%v): got %t, want %t", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	if !Is(Wrap(miss, "lookup user"), miss) {
+		t.Errorf("Is(Wrap(miss), miss): got false, want true")
+	}
+	if got := WithExpected(nil); got != nil {
+		t.Errorf("WithExpected(nil): got %#v, expected nil", got)
+	}
+}
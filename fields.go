@@ -0,0 +1,73 @@
+package errors
+
+// WithField annotates err with the field key set to value, for structured
+// logging. It does not change err's message or code.
+// If err is nil, WithField returns nil.
+func WithField(err error, key string, value interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &withFields{annotation{err}, map[string]interface{}{key: value}}
+}
+
+// WithFields annotates err with a copy of fields, for structured logging.
+// It does not change err's message or code.
+// If err is nil, WithFields returns nil.
+func WithFields(err error, fields map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &withFields{annotation{err}, cloneFields(fields)}
+}
+
+type withFields struct {
+	annotation
+	fields map[string]interface{}
+}
+
+// Fields returns the fields attached to the errors in err's chain, merged
+// into a single map. When several errors in the chain set the same key, the
+// outermost value wins. Fields returns nil if no fields are attached.
+func Fields(err error) map[string]interface{} {
+	var fields map[string]interface{}
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		for k, v := range localFields(err) {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+	}
+	return fields
+}
+
+// LayerWithField returns the innermost error in err's chain which itself
+// sets the field key, and true. It finds the layer where the field was set,
+// not the layers which merely inherit it from their causes, so the returned
+// layer's code and message describe the point at which the field was added.
+// If no layer sets key, LayerWithField returns nil and false.
+func LayerWithField(err error, key string) (error, bool) {
+	var layer error
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if _, ok := localFields(err)[key]; ok {
+			layer = err
+		}
+	}
+	return layer, layer != nil
+}
+
+// localFields returns the fields set by err itself, excluding those of its
+// causes.
+func localFields(err error) map[string]interface{} {
+	switch e := err.(type) {
+	case *MsgCodeErr:
+		return e.fields
+	case *StackError:
+		return e.fields
+	case *withFields:
+		return e.fields
+	}
+	return nil
+}
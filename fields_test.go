@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestLayerWithField(t *testing.T) {
+	pop := PushScope(map[string]interface{}{"requestID": "r1"})
+	inner := Wrap(io.EOF, "read body")
+	pop()
+	middle := WithMessage(inner, "parse request")
+	pop = PushScope(map[string]interface{}{"requestID": "r2", "userID": 7})
+	outer := Wrap(middle, "handle request")
+	pop()
+
+	tests := []struct {
+		key    string
+		want   error
+		wantOK bool
+	}{
+		{"requestID", inner, true},
+		{"userID", outer, true},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := LayerWithField(outer, tt.key)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LayerWithField(%v, %q): got (%v, %t), want (%v, %t)", outer, tt.key, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if got, ok := LayerWithField(nil, "requestID"); got != nil || ok {
+		t.Errorf("LayerWithField(nil, \"requestID\"): got (%v, %t), want (nil, false)", got, ok)
+	}
+}
+
+func TestWithField(t *testing.T) {
+	leaf := NewWithCode(404, "user not found")
+	inner := WithField(leaf, "user_id", 7)
+	outer := WithFields(Wrap(inner, "load profile"), map[string]interface{}{
+		"request_id": "r1",
+		"user_id":    8,
+	})
+	err := WithField(outer, "attempt", 2)
+
+	want := map[string]interface{}{"request_id": "r1", "user_id": 8, "attempt": 2}
+	if got := Fields(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(%v): got %v, want %v", err, got, want)
+	}
+	if got, want := Fields(inner), map[string]interface{}{"user_id": 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(%v): got %v, want %v", inner, got, want)
+	}
+
+	if got := err.(interface{ Code() int }).Code(); got != 404 {
+		t.Errorf("WithField(...).Code(): got %d, want 404", got)
+	}
+	if got := err.Error(); got != "load profile: user not found" {
+		t.Errorf("WithField(...).Error(): got %q, want %q", got, "load profile: user not found")
+	}
+	if got := Cause(err); got != leaf {
+		t.Errorf("Cause(%v): got %#v, want %#v", err, got, leaf)
+	}
+	if got := Unwrap(inner); got != leaf {
+		t.Errorf("Unwrap(%v): got %#v, want %#v", inner, got, leaf)
+	}
+
+	fields := map[string]interface{}{"k": "v"}
+	copied := WithFields(io.EOF, fields)
+	fields["k"] = "changed"
+	if got := Fields(copied)["k"]; got != "v" {
+		t.Errorf("WithFields: changing the map afterwards changed the field to %v", got)
+	}
+
+	if got := WithField(nil, "k", "v"); got != nil {
+		t.Errorf("WithField(nil): got %#v, expected nil", got)
+	}
+	if got := WithFields(nil, fields); got != nil {
+		t.Errorf("WithFields(nil): got %#v, expected nil", got)
+	}
+}
@@ -247,7 +247,7 @@ func TestFormatWithMessage(t *testing.T) {
 			"error",
 			"github.com/WeiquanWa/errors.TestFormatWithMessage\n" +
 				"\t.+/github.com/WeiquanWa/errors/format_test.go:244",
-			"error2"},
+			"error2", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:244"},
 	}, {
 		WithMessage(io.EOF, "addition1"),
 		"%s",
@@ -259,7 +259,7 @@ func TestFormatWithMessage(t *testing.T) {
 	}, {
 		WithMessage(io.EOF, "addition1"),
 		"%+v",
-		[]string{"EOF", "addition1"},
+		[]string{"EOF", "addition1", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:260"},
 	}, {
 		WithMessage(WithMessage(io.EOF, "addition1"), "addition2"),
 		"%v",
@@ -267,11 +267,11 @@ func TestFormatWithMessage(t *testing.T) {
 	}, {
 		WithMessage(WithMessage(io.EOF, "addition1"), "addition2"),
 		"%+v",
-		[]string{"EOF", "addition1", "addition2"},
+		[]string{"EOF", "addition1", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:268", "addition2", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:268"},
 	}, {
 		Wrap(WithMessage(io.EOF, "error1"), "error2"),
 		"%+v",
-		[]string{"EOF", "error1", "error2",
+		[]string{"EOF", "error1", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:272", "error2",
 			"github.com/WeiquanWa/errors.TestFormatWithMessage\n" +
 				"\t.+/github.com/WeiquanWa/errors/format_test.go:272"},
 	}, {
@@ -280,7 +280,7 @@ func TestFormatWithMessage(t *testing.T) {
 		[]string{"error1",
 			"github.com/WeiquanWa/errors.TestFormatWithMessage\n" +
 				"\t.+/github.com/WeiquanWa/errors/format_test.go:278",
-			"error2"},
+			"error2", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:278"},
 	}, {
 		WithMessage(WithStack(io.EOF), "error"),
 		"%+v",
@@ -288,7 +288,7 @@ func TestFormatWithMessage(t *testing.T) {
 			"EOF",
 			"github.com/WeiquanWa/errors.TestFormatWithMessage\n" +
 				"\t.+/github.com/WeiquanWa/errors/format_test.go:285",
-			"error"},
+			"error", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:285"},
 	}, {
 		WithMessage(Wrap(WithStack(io.EOF), "inside-error"), "outside-error"),
 		"%+v",
@@ -299,7 +299,7 @@ func TestFormatWithMessage(t *testing.T) {
 			"inside-error",
 			"github.com/WeiquanWa/errors.TestFormatWithMessage\n" +
 				"\t.+/github.com/WeiquanWa/errors/format_test.go:293",
-			"outside-error"},
+			"outside-error", "github.com/WeiquanWa/errors.TestFormatWithMessage\n\t.+/github.com/WeiquanWa/errors/format_test.go:293"},
 	}}
 
 	for i, tt := range tests {
@@ -328,7 +328,7 @@ func TestFormatGeneric(t *testing.T) {
 	wrappers := []wrapper{
 		{
 			func(err error) error { return WithMessage(err, "with-message") },
-			[]string{"with-message"},
+			[]string{"with-message", "github.com/WeiquanWa/errors.(func·001|TestFormatGeneric.func1)\n\t.+/github.com/WeiquanWa/errors/format_test.go:330"},
 		}, {
 			func(err error) error { return WithStack(err) },
 			[]string{
@@ -557,3 +557,121 @@ func testGenericRecursive(t *testing.T, beforeErr error, beforeWant []string, li
 		}
 	}
 }
+
+func TestFormatCodes(t *testing.T) {
+	root := New("row missing").WithCode(404)
+	root.stack = nil
+	query := WithMessage(root, "query")
+	query.stack = nil
+	handler := WithMessage(query, "handler").WithCode(500)
+	handler.stack = nil
+	err := WithMessage(handler, "serve")
+	err.stack = nil
+
+	tests := []struct {
+		collapse bool
+		want     string
+	}{
+		{false, "[code=404] row missing\n[code=404] query\n[code=500] handler\n[code=500] serve"},
+		{true, "[code=404] row missing\nquery\n[code=500] handler\nserve"},
+	}
+
+	for i, tt := range tests {
+		SetCollapseCodes(tt.collapse)
+		got := fmt.Sprintf("%+v", err)
+		SetCollapseCodes(false)
+		if got != tt.want {
+			t.Errorf("test %d: %%+v:\n got %q\nwant %q", i+1, got, tt.want)
+		}
+	}
+
+	if got := fmt.Sprintf("%v", err); got != "serve: handler: query: row missing" {
+		t.Errorf("%%v: got %q", got)
+	}
+	if got := fmt.Sprintf("%+v", WithMessage(io.EOF, "read")); strings.Contains(got, "[code=") {
+		t.Errorf("%%+v shows an undefined code: %q", got)
+	}
+}
+
+func TestFormatNewStack(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{NewStack(nil), 0},
+		{NewStack(io.EOF), 1},
+		{NewStack(NewStack(io.EOF)), 1},
+		{NewStack(New("error")), 1},
+		{NewStack(NewStack(New("error"))), 1},
+		{NewStack(Wrap(io.EOF, "read")), 1},
+		{NewStack(WithMessageCode(New("error"), 500, "outer")), 1},
+		{NewStack(Expected("miss")), 1},
+		{WithStack(WithStack(io.EOF)), 2},
+	}
+
+	for i, tt := range tests {
+		got := strings.Count(fmt.Sprintf("%+v", tt.err), ".TestFormatNewStack\n")
+		if got != tt.want {
+			t.Errorf("test %d: %+v: got %d traces, want %d", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	err := New("error")
+	if got := NewStack(err); got != err {
+		t.Errorf("NewStack(%v): got %#v, want the error unchanged", err, got)
+	}
+}
+
+// formatterError is a foreign error whose %+v output ends with a newline.
+type formatterError struct{ msg string }
+
+func (e formatterError) Error() string { return e.msg }
+
+func (e formatterError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%s\n\tdetail\n", e.msg)
+		return
+	}
+	fmt.Fprint(s, e.msg)
+}
+
+// tracedError is a foreign error which prints its own stack trace.
+type tracedError struct{ formatterError }
+
+func (e tracedError) StackTrace() []uintptr { return nil }
+
+func (e tracedError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%s\nforeign.Func\n\tforeign.go:1\n", e.msg)
+		return
+	}
+	fmt.Fprint(s, e.msg)
+}
+
+func TestFormatForeign(t *testing.T) {
+	plain := formatterError{"plain"}
+	traced := tracedError{formatterError{"traced"}}
+	ownTrace := "\ngithub.com/WeiquanWa/errors.TestFormatForeign\n\t.+/format_test.go:\\d+\n(?s:.*)"
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{Wrap(plain, "outer"), "plain\n\tdetail\nouter" + ownTrace},
+		{WithMessage(plain, "outer"), "plain\n\tdetail\nouter" + ownTrace},
+		{WithStack(plain), "plain\n\tdetail" + ownTrace},
+		{WithHTTPStatus(plain, 503), "plain\n\tdetail"},
+		{WithSeverity(plain, SeverityWarn), "plain\n\tdetail\n\\[severity=warn\\]"},
+		{Wrap(traced, "outer"), "traced\nforeign.Func\n\tforeign.go:1\nouter"},
+		{WithMessageCode(WithStack(traced), 503, "outer"), "traced\nforeign.Func\n\tforeign.go:1\n\\[code=503\\] outer"},
+		{Wrap(WithHTTPStatus(WithMessage(traced, "inner"), 503), "outer"), "traced\nforeign.Func\n\tforeign.go:1\ninner\nouter"},
+		{Join(plain, traced), "plain\n\tdetail\ntraced\nforeign.Func\n\tforeign.go:1"},
+	}
+
+	for i, tt := range tests {
+		got := fmt.Sprintf("%+v", tt.err)
+		if !regexp.MustCompile("^" + tt.want + "$").MatchString(got) {
+			t.Errorf("test %d: %%+v:\ngot:\n%s\nwant match of:\n%s", i+1, got, tt.want)
+		}
+	}
+}
@@ -71,6 +71,35 @@ func TestIs(t *testing.T) {
 	}
 }
 
+func TestIsCode(t *testing.T) {
+	notFound := New("not found").SetCode(404).(*MsgCodeErr)
+	unnamed := New("connection reset")
+	var fetch error = Wrap(New("gone").SetCode(404), "fetch")
+
+	tests := []struct {
+		err    error
+		target error
+		want   bool
+	}{
+		{Wrap(WithMessage(Wrap(New("user 42 missing").SetCode(404), "load user"), "handler"), "serve"), notFound, true},
+		{Wrap(WithStack(New("timeout").SetCode(504)), "call"), notFound, false},
+		{WithStack(Wrap(New("connection reset"), "dial")), unnamed, true},
+		{WithStack(Wrap(New("broken pipe"), "dial")), unnamed, false},
+		{fmt.Errorf("wrapped: %w", fetch), notFound, true},
+		{Wrap(stderrors.New("not found"), "fetch"), notFound, false},
+		{WrapWithCode(New("missing"), 404, "reclassified"), notFound, true},
+		{Wrap(WithMessageCode(stderrors.New("eof"), 404, "reclassified"), "outer"), notFound, true},
+		{Codef(404, New("timeout").SetCode(504), "reclassified"), notFound, true},
+		{WrapWithCode(New("missing").SetCode(404), 500, "reclassified"), notFound, true},
+	}
+
+	for i, tt := range tests {
+		if got := stderrors.Is(tt.err, tt.target); got != tt.want {
+			t.Errorf("test %d: Is(%v, %v): got %t, want %t", i+1, tt.err, tt.target, got, tt.want)
+		}
+	}
+}
+
 type customErr struct {
 	msg string
 }
@@ -176,3 +205,23 @@ func TestUnwrap(t *testing.T) {
 		})
 	}
 }
+
+func TestAsChain(t *testing.T) {
+	leaf := New("row missing").SetCode(404)
+	var chains = []error{
+		Wrap(WithStack(WithMessage(leaf, "query")), "load"),
+		WithPublicMessage(Wrap(WithHTTPStatus(leaf, 404), "load"), "not found"),
+		WithSeverity(WithBuildInfo(WithExpected(WithMessage(leaf, "query"))), SeverityWarn),
+	}
+
+	for i, err := range chains {
+		var mErr *MsgCodeErr
+		if !stderrors.As(err, &mErr) || mErr != leaf {
+			t.Errorf("test %d: As(%v, *MsgCodeErr): got %v, want %v", i+1, err, mErr, leaf)
+		}
+		var cErr *CauseMsgCodeError
+		if !stderrors.As(err, &cErr) {
+			t.Errorf("test %d: As(%v, *CauseMsgCodeError): got false, want true", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !go1.18
+// +build !go1.18
+
+package errors
+
+import "runtime/debug"
+
+// vcsBuildInfo does nothing: before Go 1.18, debug.BuildInfo does not
+// record the Go version or version control settings.
+func vcsBuildInfo(bi *debug.BuildInfo, j *buildJSON) {}
@@ -0,0 +1,40 @@
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import "runtime/debug"
+
+// CauseOfType returns the first error in err's chain whose concrete type is
+// T, and true. If there is no such error it returns the zero value of T and
+// false.
+//
+// The chain consists of err itself followed by the sequence of errors
+// obtained by repeatedly calling Cause or, for errors which do not implement
+// Cause, Unwrap. Unlike As, CauseOfType matches concrete types only and
+// follows Cause as well as Unwrap.
+func CauseOfType[T error](err error) (T, bool) {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if t, ok := err.(T); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// vcsBuildInfo copies the Go version and the version control settings of bi
+// to j.
+func vcsBuildInfo(bi *debug.BuildInfo, j *buildJSON) {
+	j.GoVersion = bi.GoVersion
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			j.Revision = s.Value
+		case "vcs.time":
+			j.Time = s.Value
+		case "vcs.modified":
+			j.Modified = s.Value == "true"
+		}
+	}
+}
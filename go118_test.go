@@ -0,0 +1,35 @@
+//go:build go1.18
+// +build go1.18
+
+package errors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestCauseOfType(t *testing.T) {
+	pathErr := &os.PathError{Op: "open", Path: "/etc/app.conf", Err: os.ErrNotExist}
+	err := WithMessage(Wrap(fmt.Errorf("load: %w", Wrapf(pathErr, "read %s", "config")), "start"), "main")
+
+	got, ok := CauseOfType[*os.PathError](err)
+	if !ok || got != pathErr {
+		t.Errorf("CauseOfType[*os.PathError](%v): got (%v, %t), want (%v, true)", err, got, ok, pathErr)
+	}
+
+	msgErr := New("root")
+	cause, ok := CauseOfType[*CauseMsgCodeError](Wrap(msgErr, "wrapped"))
+	if !ok || cause.Cause() != msgErr {
+		t.Errorf("CauseOfType[*CauseMsgCodeError]: got (%v, %t), want the layer wrapping %v", cause, ok, msgErr)
+	}
+
+	if got, ok := CauseOfType[*os.PathError](Wrap(io.EOF, "read")); ok || got != nil {
+		t.Errorf("CauseOfType[*os.PathError](Wrap(io.EOF)): got (%v, %t), want (nil, false)", got, ok)
+	}
+
+	if _, ok := CauseOfType[*MsgCodeErr](nil); ok {
+		t.Errorf("CauseOfType[*MsgCodeErr](nil): got true, want false")
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.20
+// +build go1.20
+
+package errors
+
+import (
+	stderrors "errors"
+	"io"
+	"testing"
+)
+
+func TestJoinedChains(t *testing.T) {
+	notFound := NewWithCode(404, "not found")
+	joined := stderrors.Join(io.EOF, Wrap(notFound, "lookup"), NewWithCode(409, "conflict"))
+	err := Wrap(joined, "batch")
+
+	if got := Cause(err); got != joined {
+		t.Errorf("Cause(%v): got %#v, want the joined error", err, got)
+	}
+
+	tests := []struct {
+		err      error
+		wantCode int
+		wantLen  int
+	}{
+		{joined, 404, 6},
+		{err, 404, 8},
+		{stderrors.Join(io.EOF, io.ErrUnexpectedEOF), ErrCodeNotDefined, 3},
+		{stderrors.Join(New("plain"), stderrors.Join(io.EOF, NewWithCode(500, "inner"))), 500, 5},
+		{Join(New("plain"), WithMessage(stderrors.Join(io.EOF, NewWithCode(503, "busy")), "call")), 503, 6},
+	}
+
+	for i, tt := range tests {
+		if got := GetCode(tt.err); got != tt.wantCode {
+			t.Errorf("test %d: GetCode(%v): got %d, want %d", i+1, tt.err, got, tt.wantCode)
+		}
+		if got := ChainLen(tt.err); got != tt.wantLen {
+			t.Errorf("test %d: ChainLen(%v): got %d, want %d", i+1, tt.err, got, tt.wantLen)
+		}
+	}
+}
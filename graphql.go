@@ -0,0 +1,37 @@
+package errors
+
+// GraphQLExtensions returns the "extensions" map of a GraphQL error
+// response for err. It contains the fields attached with WithPublicField,
+// "suggestion" and "referenceId" entries holding the suggestion and
+// reference ID attached with WithSuggestion and WithReferenceID, if any,
+// and, when GetCode finds a code for err, a "code" entry holding the name
+// the code was registered with, or the number itself if the code is not
+// registered. These entries take precedence over public fields of the same
+// name.
+//
+// Only public data is included: the result of Error, the stack trace,
+// and the internal fields returned by Fields are never part of the map.
+// If err is nil, GraphQLExtensions returns nil.
+func GraphQLExtensions(err error) map[string]interface{} {
+	if err == nil {
+		return nil
+	}
+	ext := make(map[string]interface{})
+	for k, v := range PublicFields(err) {
+		ext[k] = v
+	}
+	if suggestion, ok := Suggestion(err); ok {
+		ext["suggestion"] = suggestion
+	}
+	if id, ok := ReferenceID(err); ok {
+		ext["referenceId"] = id
+	}
+	if code := GetCode(err); code != ErrCodeNotDefined {
+		if name, ok := registeredCodeName(code); ok {
+			ext["code"] = name
+		} else {
+			ext["code"] = code
+		}
+	}
+	return ext
+}
@@ -0,0 +1,46 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// Registered once, as in codes_test.go, so that the tests can rerun.
+func init() { RegisterCode(-3001, "TEST_GRAPHQL") }
+
+func TestGraphQLExtensions(t *testing.T) {
+	defer PushScope(map[string]interface{}{"requestID": "internal"})()
+	tests := []struct {
+		err  error
+		want map[string]interface{}
+	}{
+		{nil, nil},
+		{io.EOF, map[string]interface{}{}},
+		{New("secret").SetCode(-3002), map[string]interface{}{"code": -3002}},
+		{
+			WithPublicField(Wrap(New("secret").SetCode(-3001), "internal"), "field", "email"),
+			map[string]interface{}{"code": "TEST_GRAPHQL", "field": "email"},
+		},
+		{
+			WithPublicField(New("secret").SetCode(-3001), "code", "overridden"),
+			map[string]interface{}{"code": "TEST_GRAPHQL"},
+		},
+		{
+			fmt.Errorf("resolver: %w", New("secret").SetCode(-3001)),
+			map[string]interface{}{"code": "TEST_GRAPHQL"},
+		},
+		{
+			WithSuggestion(io.EOF, "retry later"),
+			map[string]interface{}{"suggestion": "retry later"},
+		},
+	}
+
+	for i, tt := range tests {
+		got := GraphQLExtensions(tt.err)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: GraphQLExtensions(%v): got %v, want %v", i+1, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package errors
+
+// newHooks are called with every error created by a constructor, such as New.
+// They are registered by init functions and never modified afterwards,
+// so they may be read without synchronisation.
+var newHooks []func(error)
+
+// codeHooks are called with every code assigned to an error, whether by
+// SetCode or by a function, such as Codef, which sets a code while wrapping.
+// Like newHooks, they are only registered by init functions.
+var codeHooks []func(code int)
+
+// onNew runs the registered newHooks for err.
+func onNew(err error) {
+	for _, hook := range newHooks {
+		hook(err)
+	}
+}
+
+// onCode runs the registered codeHooks for code.
+func onCode(code int) {
+	for _, hook := range codeHooks {
+		hook(code)
+	}
+}
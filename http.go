@@ -0,0 +1,169 @@
+package errors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// WithHTTPStatus annotates err with the HTTP status that should be reported
+// to clients for it.
+// If err is nil, WithHTTPStatus returns nil.
+func WithHTTPStatus(err error, status int) error {
+	if err == nil {
+		return nil
+	}
+	return &withHTTPStatus{annotation{err}, status}
+}
+
+type withHTTPStatus struct {
+	annotation
+	status int
+}
+
+// codeHTTPStatuses holds the statuses registered with RegisterHTTPStatus.
+var codeHTTPStatuses = struct {
+	sync.RWMutex
+	m map[int]int
+}{m: make(map[int]int)}
+
+// RegisterHTTPStatus makes status the HTTP status reported by HTTPStatus
+// for errors with the given code which have no status attached with
+// WithHTTPStatus, replacing any previous registration. It is safe for
+// concurrent use, but is usually called from init functions.
+func RegisterHTTPStatus(code, status int) {
+	codeHTTPStatuses.Lock()
+	defer codeHTTPStatuses.Unlock()
+	codeHTTPStatuses.m[code] = status
+}
+
+// HTTPStatus returns the HTTP status that should be reported to clients for
+// err. The precedence is:
+//
+//  1. the outermost status attached with WithHTTPStatus;
+//  2. the status registered with RegisterHTTPStatus for the code of err,
+//     as found by GetCode;
+//  3. http.StatusInternalServerError.
+//
+// If err is nil, HTTPStatus returns http.StatusOK.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		if w, ok := e.(*withHTTPStatus); ok {
+			return w.status
+		}
+	}
+	if code := GetCode(err); code != ErrCodeNotDefined {
+		codeHTTPStatuses.RLock()
+		status, ok := codeHTTPStatuses.m[code]
+		codeHTTPStatuses.RUnlock()
+		if ok {
+			return status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// httpBody is the JSON shape written by HTTPResponse.
+type httpBody struct {
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Suggestion  string `json:"suggestion,omitempty"`
+	ReferenceID string `json:"reference_id,omitempty"`
+}
+
+// HTTPResponse returns the HTTP status and JSON response body for err.
+// The body has the form
+//
+//	{"code": <code>, "message": "<public message>", "suggestion": "<suggestion>", "reference_id": "<id>"}
+//
+// where the code is that returned by GetCode, and the status, message,
+// suggestion and reference ID are those attached by WithHTTPStatus,
+// WithPublicMessage, WithSuggestion and WithReferenceID. If no public
+// message was attached, the standard text for the status is used. The
+// suggestion and reference ID are omitted if none was attached. The result
+// of Error, the stack trace and the rest of the chain are never included in
+// the body.
+func HTTPResponse(err error) (status int, body []byte) {
+	status = HTTPStatus(err)
+	code := GetCode(err)
+	msg, ok := PublicMessage(err)
+	if !ok {
+		msg = http.StatusText(status)
+	}
+	suggestion, _ := Suggestion(err)
+	id, _ := ReferenceID(err)
+	body, _ = json.Marshal(httpBody{Code: code, Message: msg, Suggestion: suggestion, ReferenceID: id})
+	return status, body
+}
+
+// maxBodySnippet is the maximum number of bytes of a response body included
+// in the message of errors returned by FromHTTPResponse.
+const maxBodySnippet = 256
+
+// FromHTTPResponse returns an error describing resp if its status is not in
+// the 2xx range, and nil otherwise. The error's code is the HTTP status, it
+// carries the status as if annotated by WithHTTPStatus, and its message has
+// the form
+//
+//	<method> <url>: <status>: <body>
+//
+// where the method and URL are omitted if resp.Request is nil and body is
+// the start of the supplied response body, with surrounding white space
+// removed. At most 256 bytes of the body are included; longer bodies are
+// truncated and marked with "...". FromHTTPResponse does not read or close
+// resp.Body; callers pass the body they have already read. The URL is
+// written without its query and with any password redacted, so that
+// credentials do not end up in logs.
+// FromHTTPResponse also records the stack trace at the point it was called.
+func FromHTTPResponse(resp *http.Response, body []byte) error {
+	if resp == nil || resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var msg strings.Builder
+	if req := resp.Request; req != nil && req.URL != nil {
+		u := *req.URL
+		u.RawQuery, u.ForceQuery = "", false
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		msg.WriteString(req.Method + " " + u.String() + ": ")
+	}
+	msg.WriteString(strconv.Itoa(resp.StatusCode))
+	if text := http.StatusText(resp.StatusCode); text != "" {
+		msg.WriteString(" " + text)
+	}
+	if snippet := bodySnippet(body); snippet != "" {
+		msg.WriteString(": " + snippet)
+	}
+
+	err := &MsgCodeErr{
+		msg:    msg.String(),
+		code:   int64(resp.StatusCode),
+		fields: scopeFields(),
+	}
+	err.stack = callers(err)
+	onNew(err)
+	return &withHTTPStatus{annotation{err}, resp.StatusCode}
+}
+
+// bodySnippet returns the start of body, trimmed of white space and cut to
+// at most maxBodySnippet bytes without splitting a UTF-8 sequence.
+func bodySnippet(body []byte) string {
+	s := strings.TrimSpace(string(body))
+	if len(s) <= maxBodySnippet {
+		return s
+	}
+	n := maxBodySnippet
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
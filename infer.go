@@ -0,0 +1,48 @@
+package errors
+
+import (
+	"regexp"
+	"sync"
+)
+
+// inferRules holds the rules registered with RegisterInferRule, in
+// registration order.
+var inferRules struct {
+	sync.RWMutex
+	rules []inferRule
+}
+
+type inferRule struct {
+	re   *regexp.Regexp
+	code int
+}
+
+// RegisterInferRule adds a rule to InferCode: errors whose message matches
+// re are given the supplied code. Rules are evaluated in the order they were
+// registered. It is safe to call RegisterInferRule concurrently with
+// InferCode, but rules are usually registered from init functions.
+func RegisterInferRule(re *regexp.Regexp, code int) {
+	inferRules.Lock()
+	defer inferRules.Unlock()
+	inferRules.rules = append(inferRules.rules, inferRule{re, code})
+}
+
+// InferCode matches the result of err.Error() against the rules registered
+// with RegisterInferRule and returns the code of the first rule which
+// matches, in registration order. It returns ErrCodeNotDefined if no rule
+// matches or err is nil. InferCode is meant for classifying third-party
+// errors which carry only a message; it ignores any code err already has.
+func InferCode(err error) int {
+	if err == nil {
+		return ErrCodeNotDefined
+	}
+	msg := err.Error()
+	inferRules.RLock()
+	defer inferRules.RUnlock()
+	for _, rule := range inferRules.rules {
+		if rule.re.MatchString(msg) {
+			return rule.code
+		}
+	}
+	return ErrCodeNotDefined
+}
@@ -0,0 +1,33 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+)
+
+func TestInferCode(t *testing.T) {
+	RegisterInferRule(regexp.MustCompile(`^pq: duplicate key value`), -6001)
+	RegisterInferRule(regexp.MustCompile(`connection refused`), -6002)
+	RegisterInferRule(regexp.MustCompile(`refused`), -6003)
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, ErrCodeNotDefined},
+		{io.EOF, ErrCodeNotDefined},
+		{fmt.Errorf("pq: duplicate key value violates unique constraint"), -6001},
+		{fmt.Errorf("dial tcp: connection refused"), -6002},
+		{Wrap(fmt.Errorf("permission refused"), "open"), -6003},
+		{Wrap(fmt.Errorf("pq: duplicate key value"), "insert"), ErrCodeNotDefined},
+	}
+
+	for i, tt := range tests {
+		got := InferCode(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: InferCode(%v): got %d, want %d", i+1, tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Join returns an error that wraps the given errors, preserving the code
+// of each. Nil errors are discarded; Join returns nil if every error is
+// nil. The message of the returned error is the messages of the errors,
+// separated by newlines.
+//
+// The returned error implements Unwrap() []error, so that Is and As of Go
+// 1.20 and later examine every joined error, and
+//
+//	interface {
+//	        Codes() []int
+//	}
+//
+// which returns the code of each joined error, as reported by GetCode.
+func Join(errs ...error) error {
+	n := 0
+	for _, err := range errs {
+		if err != nil {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	m := &multiError{errs: make([]error, 0, n)}
+	for _, err := range errs {
+		if err != nil {
+			m.errs = append(m.errs, err)
+		}
+	}
+	return m
+}
+
+type multiError struct {
+	errs []error
+}
+
+func (m *multiError) Error() string {
+	msgs := make([]string, len(m.errs))
+	for i, err := range m.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the joined errors.
+func (m *multiError) Unwrap() []error { return m.errs }
+
+// Codes returns the code of each joined error, as reported by GetCode.
+func (m *multiError) Codes() []int {
+	codes := make([]int, len(m.errs))
+	for i, err := range m.errs {
+		codes[i] = GetCode(err)
+	}
+	return codes
+}
+
+// Format implements fmt.Formatter. With %+v, each joined error is printed
+// with %+v, including its stack trace, separated by newlines.
+func (m *multiError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			for i, err := range m.errs {
+				if i > 0 {
+					_, _ = io.WriteString(s, "\n")
+				}
+				formatCause(s, err)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		_, _ = io.WriteString(s, m.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(s, "%q", m.Error())
+	}
+}
@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJoin(t *testing.T) {
+	name := New("name is required").SetCode(1001)
+	age := Wrap(New("age must be positive").SetCode(1002), "validate")
+
+	tests := []struct {
+		errs      []error
+		wantMsg   string
+		wantCodes []int
+	}{
+		{nil, "", nil},
+		{[]error{nil, nil}, "", nil},
+		{[]error{name}, "name is required", []int{1001}},
+		{[]error{nil, name, nil, age, io.EOF}, "name is required\nvalidate: age must be positive\nEOF", []int{1001, 1002, ErrCodeNotDefined}},
+	}
+
+	for i, tt := range tests {
+		err := Join(tt.errs...)
+		if tt.wantCodes == nil {
+			if err != nil {
+				t.Errorf("test %d: Join(%v): got %v, want nil", i+1, tt.errs, err)
+			}
+			continue
+		}
+		if err.Error() != tt.wantMsg {
+			t.Errorf("test %d: Join(%v): got %q, want %q", i+1, tt.errs, err.Error(), tt.wantMsg)
+		}
+		codes := err.(interface{ Codes() []int }).Codes()
+		if !reflect.DeepEqual(codes, tt.wantCodes) {
+			t.Errorf("test %d: Join(%v).Codes(): got %v, want %v", i+1, tt.errs, codes, tt.wantCodes)
+		}
+		if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != len(tt.wantCodes) {
+			t.Errorf("test %d: Join(%v).Unwrap(): got %d errors, want %d", i+1, tt.errs, n, len(tt.wantCodes))
+		}
+	}
+}
+
+func TestJoinFormat(t *testing.T) {
+	err := Join(New("first"), io.EOF, Wrap(New("second"), "wrapped"))
+
+	if got := fmt.Sprintf("%v", err); got != "first\nEOF\nwrapped: second" {
+		t.Errorf("%%v: got %q", got)
+	}
+	got := fmt.Sprintf("%+v", err)
+	if n := strings.Count(got, "errors.TestJoinFormat\n"); n != 3 {
+		t.Errorf("%%+v: got %d stack traces, want 3:\n%s", n, got)
+	}
+	for _, msg := range []string{"first\n", "EOF\n", "second\n", "wrapped\n"} {
+		if !strings.Contains(got, msg) {
+			t.Errorf("%%+v: output does not contain %q:\n%s", msg, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonError is the JSON shape of the errors of this package:
+//
+//	{"code": <code>, "message": "<message>", "cause": <cause or null>}
+//
+// CauseMsgCodeError adds "cause_code", and errors which recorded a stack
+// trace add "stack", an array of "file:line" strings.
+type jsonError struct {
+	Code      int             `json:"code"`
+	CauseCode *int            `json:"cause_code,omitempty"`
+	Message   string          `json:"message"`
+	Cause     json.RawMessage `json:"cause"`
+	Stack     []string        `json:"stack,omitempty"`
+}
+
+// depthMarshaler is implemented by the errors of this package which encode
+// the error they wrap, so that the depth reached is carried through their
+// nested encodings.
+type depthMarshaler interface {
+	marshalJSON(depth int) ([]byte, error)
+}
+
+// marshalError returns the JSON encoding of err. Errors which implement
+// json.Marshaler encode themselves; other errors are encoded in the shape
+// of jsonError, with their message, their code if they have one, and their
+// cause. The chain is followed for at most maxDepth links, even when it
+// passes through the errors of this package, so that cyclic chains cannot
+// recurse without limit.
+func marshalError(err error, depth int) (json.RawMessage, error) {
+	if err == nil || depth >= maxDepth {
+		return json.RawMessage("null"), nil
+	}
+	if m, ok := err.(depthMarshaler); ok {
+		return m.marshalJSON(depth)
+	}
+	if m, ok := err.(json.Marshaler); ok {
+		return m.MarshalJSON()
+	}
+	code := ErrCodeNotDefined
+	if cErr, ok := err.(interface{ Code() int }); ok {
+		code = cErr.Code()
+	}
+	cause, jerr := marshalError(next(err), depth+1)
+	if jerr != nil {
+		return nil, jerr
+	}
+	return json.Marshal(jsonError{Code: code, Message: err.Error(), Cause: cause})
+}
+
+// jsonFrame is the JSON shape of a Frame.
+type jsonFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// MarshalJSON implements json.Marshaler. The frame is encoded as
+//
+//	{"func": "<function>", "file": "<path of source file>", "line": <line>}
+//
+// with the values returned by Function, File and Line, so that a frame
+// which cannot be resolved is encoded with empty strings and line 0.
+func (f Frame) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonFrame{Func: f.Function(), File: f.File(), Line: f.Line()})
+}
+
+// MarshalJSON implements json.Marshaler. The stack trace is encoded as an
+// array holding each Frame in the shape written by Frame.MarshalJSON,
+// innermost first. An empty stack trace is encoded as [].
+func (st StackTrace) MarshalJSON() ([]byte, error) {
+	frames := make([]jsonFrame, len(st))
+	for i, f := range st {
+		frames[i] = jsonFrame{Func: f.Function(), File: f.File(), Line: f.Line()}
+	}
+	return json.Marshal(frames)
+}
+
+// jsonStack returns the frames of s as "file:line" strings.
+func jsonStack(s *stack) []string {
+	var frames []string
+	for _, f := range s.StackTrace() {
+		frames = append(frames, fmt.Sprintf("%v", f))
+	}
+	return frames
+}
+
+// MarshalJSON implements json.Marshaler. The error is encoded as
+//
+//	{"code": <code>, "message": "<message>", "cause": null, "stack": ["file.go:42", ...]}
+//
+// where the stack is omitted if none was recorded.
+func (f *MsgCodeErr) MarshalJSON() ([]byte, error) {
+	if f == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(jsonError{
+		Code:    f.Code(),
+		Message: f.msg,
+		Cause:   json.RawMessage("null"),
+		Stack:   jsonStack(f.stack),
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The error is encoded as
+//
+//	{"code": <code>, "message": "<message>", "cause": <cause>, "stack": ["file.go:42", ...]}
+//
+// where the message is the full result of Error, and the cause is the JSON
+// encoding of the wrapped error.
+func (w *StackError) MarshalJSON() ([]byte, error) {
+	if w == nil {
+		return []byte("null"), nil
+	}
+	return w.marshalJSON(0)
+}
+
+func (w *StackError) marshalJSON(depth int) ([]byte, error) {
+	cause, err := marshalError(w.error, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonError{
+		Code:    w.Code(),
+		Message: w.Error(),
+		Cause:   cause,
+		Stack:   jsonStack(w.stack),
+	})
+}
+
+// MarshalJSON implements json.Marshaler. The error is encoded as
+//
+//	{"code": <code>, "cause_code": <cause code>, "message": "<message>", "cause": <cause>}
+//
+// where the message is the one added by this layer, without that of its
+// cause, and the cause is the JSON encoding of the wrapped error. Errors
+// created by WithMessage and WithMessagef add their stack trace as for
+// StackError.
+func (w *CauseMsgCodeError) MarshalJSON() ([]byte, error) {
+	if w == nil {
+		return []byte("null"), nil
+	}
+	return w.marshalJSON(0)
+}
+
+func (w *CauseMsgCodeError) marshalJSON(depth int) ([]byte, error) {
+	cause, err := marshalError(w.cause, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	causeCode := w.causeCode
+	return json.Marshal(jsonError{
+		Code:      w.Code(),
+		CauseCode: &causeCode,
+		Message:   w.message(),
+		Cause:     cause,
+		Stack:     jsonStack(w.stack),
+	})
+}
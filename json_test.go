@@ -2,6 +2,7 @@ package errors
 
 import (
 	"encoding/json"
+	"io"
 	"regexp"
 	"testing"
 )
@@ -34,10 +35,10 @@ func TestFrameMarshalJSON(t *testing.T) {
 		want string
 	}{{
 		initpc,
-		`^"github\.com/WeiquanWa/errors\.init(\.ializers)? .+/github\.com/pkg/errors/stack_test.go:\d+"$`,
+		`^\{"func":"github\.com/WeiquanWa/errors\.init(\.ializers)?","file":".+/errors/stack_test\.go","line":\d+\}$`,
 	}, {
 		0,
-		`^"unknown"$`,
+		`^\{"func":"","file":"","line":0\}$`,
 	}}
 	for i, tt := range tests {
 		got, err := json.Marshal(tt.Frame)
@@ -49,3 +50,133 @@ func TestFrameMarshalJSON(t *testing.T) {
 		}
 	}
 }
+
+func TestStackTraceMarshalJSON(t *testing.T) {
+	type frame struct {
+		Func string `json:"func"`
+		File string `json:"file"`
+		Line int    `json:"line"`
+	}
+
+	st := New("error").StackTrace()
+	st = append(st, 0)
+	data, err := json.Marshal(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []frame
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %v", data, err)
+	}
+	if len(got) != len(st) {
+		t.Fatalf("MarshalJSON: got %d frames, want %d", len(got), len(st))
+	}
+	for i, f := range st {
+		if want := (frame{f.Function(), f.File(), f.Line()}); got[i] != want {
+			t.Errorf("frame %d: got %+v, want %+v", i, got[i], want)
+		}
+	}
+	if got[0].Func != "github.com/WeiquanWa/errors.TestStackTraceMarshalJSON" || got[0].Line == 0 {
+		t.Errorf("frame 0: got %+v, want the caller's frame", got[0])
+	}
+	if last := got[len(got)-1]; last != (frame{}) {
+		t.Errorf("unknown frame: got %+v, want zero values", last)
+	}
+
+	for _, st := range []StackTrace{nil, {}} {
+		if data, err := json.Marshal(st); err != nil || string(data) != "[]" {
+			t.Errorf("MarshalJSON(%#v): got (%s, %v), want ([], nil)", st, data, err)
+		}
+	}
+}
+
+func TestErrorMarshalJSON(t *testing.T) {
+	root := New("row missing").SetCode(404).(*MsgCodeErr)
+	root.stack = nil
+	inner := WithMessage(root, "query")
+	inner.stack = nil
+	outer := Wrap(inner, "load user")
+	outer.stack = nil
+	eof := WithMessage(io.EOF, "read")
+	eof.stack = nil
+	_ = eof.SetCode(500)
+
+	tests := []struct {
+		err  error
+		want string
+	}{{
+		root,
+		`{"code":404,"message":"row missing","cause":null}`,
+	}, {
+		inner,
+		`{"code":404,"cause_code":404,"message":"query","cause":{"code":404,"message":"row missing","cause":null}}`,
+	}, {
+		outer,
+		`{"code":404,"message":"load user: query: row missing","cause":` +
+			`{"code":404,"cause_code":404,"message":"load user","cause":` +
+			`{"code":404,"cause_code":404,"message":"query","cause":{"code":404,"message":"row missing","cause":null}}}}`,
+	}, {
+		eof,
+		`{"code":500,"cause_code":-1,"message":"read","cause":{"code":-1,"message":"EOF","cause":null}}`,
+	}, {
+		(*MsgCodeErr)(nil),
+		`null`,
+	}}
+
+	for i, tt := range tests {
+		got, err := json.Marshal(tt.err)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("test %d: json.Marshal(%v):\n got %s\nwant %s", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorMarshalJSONStack(t *testing.T) {
+	b, err := json.Marshal(Wrap(io.EOF, "read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Message string
+		Cause   struct{ Message string }
+		Stack   []string
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Message != "read: EOF" || got.Cause.Message != "read" {
+		t.Errorf("json.Marshal(Wrap(io.EOF, \"read\")): got %s", b)
+	}
+	if len(got.Stack) == 0 || !regexp.MustCompile(`^json_test\.go:\d+$`).MatchString(got.Stack[0]) {
+		t.Errorf("json.Marshal(Wrap(io.EOF, \"read\")): got stack %q, want the call site first", got.Stack)
+	}
+}
+
+// loop is a foreign error whose Cause may be any error, allowing tests to
+// build cycles which pass through the errors of this package.
+type loop struct {
+	next error
+}
+
+func (l *loop) Error() string { return "loop" }
+func (l *loop) Cause() error  { return l.next }
+
+func TestErrorMarshalJSONCyclic(t *testing.T) {
+	a, b := &loop{}, &loop{}
+	a.next = WithMessageCode(a, 1, "x")
+	b.next = Wrap(WithStack(b), "y")
+
+	for i, err := range []error{a.next, b.next, WithMessage(a, "z")} {
+		got, jerr := json.Marshal(err)
+		if jerr != nil {
+			t.Errorf("test %d: json.Marshal: %v", i+1, jerr)
+			continue
+		}
+		if !json.Valid(got) {
+			t.Errorf("test %d: json.Marshal: got invalid JSON %s", i+1, got)
+		}
+	}
+}
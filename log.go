@@ -0,0 +1,64 @@
+package errors
+
+import "sync/atomic"
+
+// logger holds the func(error) configured by SetLogger.
+var logger atomic.Value
+
+// SetLogger sets the function WrapLog uses to log the errors it creates.
+// Passing nil removes the logger.
+func SetLogger(log func(error)) {
+	logger.Store(log)
+}
+
+// WrapLog returns an error annotating err with a stack trace
+// at the point WrapLog is called, and the supplied message, exactly as Wrap
+// does. If a logger has been set with SetLogger, it is then called with the
+// new error before WrapLog returns; logging happens synchronously, on the
+// calling goroutine, at the wrap site.
+// If err is nil, WrapLog returns nil and logs nothing.
+func WrapLog(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	checkMessage("WrapLog", message)
+
+	cErr := annotate(err, message)
+	err = &StackError{
+		error:  cErr,
+		stack:  callers(cErr),
+		fields: scopeFields(),
+	}
+	if log, _ := logger.Load().(func(error)); log != nil {
+		log(err)
+	}
+	return err
+}
+
+// MarkLogged annotates err to record that it has been logged, so that
+// callers further up the stack can use WasLogged to avoid logging it again.
+// The mark is advisory: nothing in this package consults it. MarkLogged does
+// not modify err, so marking an error shared between goroutines is safe; only
+// holders of the returned error see the mark.
+// If err is nil, MarkLogged returns nil.
+func MarkLogged(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &withLogged{annotation{err}}
+}
+
+type withLogged struct {
+	annotation
+}
+
+// WasLogged reports whether any error in err's chain was marked with
+// MarkLogged. The mark survives further wrapping.
+func WasLogged(err error) bool {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if _, ok := err.(*withLogged); ok {
+			return true
+		}
+	}
+	return false
+}
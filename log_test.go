@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestWrapLog(t *testing.T) {
+	var logged []error
+	SetLogger(func(err error) { logged = append(logged, err) })
+	defer SetLogger(nil)
+
+	if got := WrapLog(nil, "no error"); got != nil {
+		t.Errorf("WrapLog(nil, \"no error\"): got %#v, expected nil", got)
+	}
+	if len(logged) != 0 {
+		t.Fatalf("WrapLog(nil, \"no error\"): logged %v, want nothing", logged)
+	}
+
+	err := WrapLog(io.EOF, "read error")
+	if got, want := err.Error(), "read error: EOF"; got != want {
+		t.Errorf("WrapLog(io.EOF, \"read error\"): got %q, want %q", got, want)
+	}
+	if len(logged) != 1 || logged[0] != err {
+		t.Fatalf("WrapLog(io.EOF, \"read error\"): logged %v, want [%v]", logged, err)
+	}
+
+	st := err.(*StackError).StackTrace()
+	if got := fmt.Sprintf("%n", st[0]); got != "TestWrapLog" {
+		t.Errorf("WrapLog stack: got top frame %q, want %q", got, "TestWrapLog")
+	}
+}
+
+func TestWrapLogWithoutLogger(t *testing.T) {
+	err := WrapLog(New("x").SetCode(404), "read error")
+	if got, want := err.Error(), "read error: x"; got != want {
+		t.Errorf("WrapLog: got %q, want %q", got, want)
+	}
+	if got := err.(*StackError).Code(); got != 404 {
+		t.Errorf("WrapLog: got code %d, want %d", got, 404)
+	}
+}
+
+func TestMarkLogged(t *testing.T) {
+	if got := MarkLogged(nil); got != nil {
+		t.Errorf("MarkLogged(nil): got %#v, expected nil", got)
+	}
+
+	err := New("x").SetCode(404)
+	if WasLogged(err) {
+		t.Errorf("WasLogged(%v): got true, want false", err)
+	}
+
+	marked := MarkLogged(err)
+	wrapped := Wrap(WithMessage(marked, "inner"), "outer")
+	for _, e := range []error{marked, wrapped} {
+		if !WasLogged(e) {
+			t.Errorf("WasLogged(%v): got false, want true", e)
+		}
+	}
+	if WasLogged(err) {
+		t.Errorf("WasLogged(%v) after marking a wrapper: got true, want false", err)
+	}
+	if got := marked.Error(); got != "x" {
+		t.Errorf("MarkLogged(%v).Error(): got %q, want %q", err, got, "x")
+	}
+	if got := wrapped.Code(); got != 404 {
+		t.Errorf("Wrap(MarkLogged(err)).Code(): got %d, want %d", got, 404)
+	}
+	if WasLogged(nil) {
+		t.Errorf("WasLogged(nil): got true, want false")
+	}
+}
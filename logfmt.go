@@ -0,0 +1,104 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// logfmtOmitStack is non-zero when Logfmt leaves out the stack key.
+var logfmtOmitStack int32
+
+// SetLogfmtStack controls whether the lines written by Logfmt include the
+// stack key. It is included by default; pipelines which only need the
+// message, code and fields can leave it out to keep lines short.
+func SetLogfmtStack(include bool) {
+	var v int32
+	if !include {
+		v = 1
+	}
+	atomic.StoreInt32(&logfmtOmitStack, v)
+}
+
+// Logfmt returns err formatted as a logfmt line, for example
+//
+//	msg="load user: not found" code=404 ref=K7QX2M4P requestID=r1 stack="main.load /src/app/user.go:42"
+//
+// The line holds the result of Error under msg, the code of err as found by
+// GetCode under code if it is defined, the reference ID attached with
+// WithReferenceID under ref if there is one, the Fields of err sorted by
+// key, and, if err or an error in its chain has a stack trace, the
+// innermost frame of the outermost stack trace under stack; see
+// SetLogfmtStack to omit it. Values containing spaces, quotes, equal signs
+// or control characters are quoted; characters other than those allowed in
+// logfmt keys are replaced by underscores in field names.
+// If err is nil, Logfmt returns the empty string.
+func Logfmt(err error) string {
+	if err == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("msg=" + logfmtValue(err.Error()))
+	if code := GetCode(err); code != ErrCodeNotDefined {
+		b.WriteString(" code=" + strconv.Itoa(code))
+	}
+	if id, ok := ReferenceID(err); ok {
+		b.WriteString(" ref=" + logfmtValue(id))
+	}
+
+	fields := Fields(err)
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" " + logfmtKey(k) + "=" + logfmtValue(fmt.Sprint(fields[k])))
+	}
+
+	if atomic.LoadInt32(&logfmtOmitStack) != 0 {
+		return b.String()
+	}
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		if st, ok := e.(interface{ StackTrace() StackTrace }); ok && len(st.StackTrace()) > 0 {
+			frame, _ := st.StackTrace()[0].MarshalText()
+			b.WriteString(" stack=" + logfmtValue(string(frame)))
+			break
+		}
+	}
+	return b.String()
+}
+
+// logfmtKey replaces the characters of key which may not appear in a
+// logfmt key with underscores.
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if logfmtUnsafe(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes value if it is empty or contains characters which
+// would otherwise end or corrupt a logfmt value.
+func logfmtValue(value string) string {
+	if value == "" || strings.IndexFunc(value, logfmtUnsafe) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// logfmtUnsafe reports whether r may not appear in an unquoted logfmt key
+// or value.
+func logfmtUnsafe(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError ||
+		unicode.IsSpace(r) || unicode.IsControl(r)
+}
@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+)
+
+func TestLogfmt(t *testing.T) {
+	pop := PushScope(map[string]interface{}{"requestID": "r1", "user name": "Ann O'Neil", "empty": "", "n": 3})
+	err := Wrap(New("not found").SetCode(404), "load user")
+	pop()
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "^$"},
+		{io.EOF, `^msg=EOF$`},
+		{fmt.Errorf(`say "hi"`), `^msg="say \\"hi\\""$`},
+		{fmt.Errorf("a=b"), `^msg="a=b"$`},
+		{fmt.Errorf("line1\nline2\ttab"), `^msg="line1\\nline2\\ttab"$`},
+		{fmt.Errorf(`back\slash`), `^msg="back\\\\slash"$`},
+		{
+			err,
+			`^msg="load user: not found" code=404 empty="" n=3 requestID=r1 user_name="Ann O'Neil" ` +
+				`stack="github.com/WeiquanWa/errors.TestLogfmt .+/logfmt_test.go:\d+"$`,
+		},
+		{
+			fmt.Errorf("handler: %w", WithReferenceID(NewLight(503, "busy"))),
+			`^msg="handler: busy" code=503 ref=[A-Z2-7]{8}$`,
+		},
+	}
+
+	for i, tt := range tests {
+		got := Logfmt(tt.err)
+		if !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("test %d: Logfmt(%v):\n got: %s\nwant: %s", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSetLogfmtStack(t *testing.T) {
+	err := New("not found").SetCode(404)
+
+	SetLogfmtStack(false)
+	got := Logfmt(err)
+	SetLogfmtStack(true)
+
+	if want := "msg=\"not found\" code=404"; got != want {
+		t.Errorf("Logfmt with SetLogfmtStack(false): got %s, want %s", got, want)
+	}
+	if got := Logfmt(err); !regexp.MustCompile(` stack=".+/logfmt_test.go:\d+"$`).MatchString(got) {
+		t.Errorf("Logfmt with SetLogfmtStack(true): got %s, want a stack key", got)
+	}
+}
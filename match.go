@@ -0,0 +1,119 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LayerSpec describes one expected layer of an error chain.
+type LayerSpec struct {
+	// Code is the code the layer must report. Use ErrCodeNotDefined for
+	// layers without a code.
+	Code int
+
+	// Message must be contained in the message added by the layer itself,
+	// excluding the message of its cause. The empty string matches any
+	// message.
+	Message string
+}
+
+// ChainSpec describes an error chain, from outermost to innermost layer.
+type ChainSpec []LayerSpec
+
+// MatchChain compares the chain of err against spec and returns nil if they
+// match, or an error describing every layer which differs. It is intended
+// for tests, where it pinpoints the layer which diverged rather than
+// comparing the whole message.
+//
+// Only the layers which add a message of their own take part in the
+// comparison: layers such as those created by WithStack, which add only a
+// stack trace, and annotations which add only metadata are skipped. Stack
+// traces are never compared.
+func MatchChain(err error, spec ChainSpec) error {
+	layers := messageLayers(err)
+	var b strings.Builder
+	mismatch := false
+	for i := 0; i < len(layers) || i < len(spec); i++ {
+		switch {
+		case i >= len(spec):
+			mismatch = true
+			fmt.Fprintf(&b, "\n  layer %d: unexpected code=%d message=%q", i, layers[i].Code, layers[i].Message)
+		case i >= len(layers):
+			mismatch = true
+			fmt.Fprintf(&b, "\n  layer %d: missing, want code=%d message containing %q", i, spec[i].Code, spec[i].Message)
+		case layers[i].Code != spec[i].Code || !strings.Contains(layers[i].Message, spec[i].Message):
+			mismatch = true
+			fmt.Fprintf(&b, "\n  layer %d: got code=%d message=%q, want code=%d message containing %q",
+				i, layers[i].Code, layers[i].Message, spec[i].Code, spec[i].Message)
+		default:
+			fmt.Fprintf(&b, "\n  layer %d: ok code=%d message=%q", i, layers[i].Code, layers[i].Message)
+		}
+	}
+	if !mismatch {
+		return nil
+	}
+	return New("errors: chain does not match spec:" + b.String())
+}
+
+// messageLayers describes the layers of err's chain which add a message of
+// their own, from outermost to innermost.
+func messageLayers(err error) []LayerSpec {
+	var layers []LayerSpec
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		msg, ok := localMessage(e)
+		if !ok {
+			continue
+		}
+		code := ErrCodeNotDefined
+		if cErr, ok := e.(interface{ Code() int }); ok {
+			code = cErr.Code()
+		}
+		layers = append(layers, LayerSpec{Code: code, Message: msg})
+	}
+	return layers
+}
+
+// DiffChains compares the chains of a and b layer by layer and returns a
+// line-oriented diff of their messages and codes, or the empty string if
+// they are the same. Layers present in both chains and equal are prefixed
+// with two spaces; differing layers appear as a line prefixed with "- " for
+// a and a line prefixed with "+ " for b. Layers are compared by position,
+// so chains of different depths show the extra layers of the deeper one.
+//
+// As with MatchChain, only layers which add a message of their own take
+// part, and stack traces are ignored. DiffChains is a debugging aid; its
+// output format may change.
+func DiffChains(a, b error) string {
+	la, lb := messageLayers(a), messageLayers(b)
+	var buf strings.Builder
+	same := true
+	for i := 0; i < len(la) || i < len(lb); i++ {
+		if i < len(la) && i < len(lb) && la[i] == lb[i] {
+			fmt.Fprintf(&buf, "  %d: code=%d %q\n", i, la[i].Code, la[i].Message)
+			continue
+		}
+		same = false
+		if i < len(la) {
+			fmt.Fprintf(&buf, "- %d: code=%d %q\n", i, la[i].Code, la[i].Message)
+		}
+		if i < len(lb) {
+			fmt.Fprintf(&buf, "+ %d: code=%d %q\n", i, lb[i].Code, lb[i].Message)
+		}
+	}
+	if same {
+		return ""
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Equal reports whether a and b have the same code, as reported by GetCode,
+// and the same message, as reported by Error. Stack traces and the
+// structure of the chains are ignored, which makes Equal suitable for
+// comparing expected and actual errors in tests. Two nil errors are equal;
+// a nil error is not equal to a non-nil one.
+func Equal(a, b error) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return GetCode(a) == GetCode(b) && a.Error() == b.Error()
+}
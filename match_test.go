@@ -0,0 +1,134 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestMatchChain(t *testing.T) {
+	err := Wrap(WithStack(WithMessage(New("duplicate key").SetCode(409), "insert user")), "create account")
+	var read error = Wrap(io.EOF, "read")
+
+	tests := []struct {
+		err  error
+		spec ChainSpec
+		want string
+	}{{
+		err: err,
+		spec: ChainSpec{
+			{Code: 409, Message: "create account"},
+			{Code: 409, Message: "insert"},
+			{Code: 409, Message: "duplicate"},
+		},
+	}, {
+		err: fmt.Errorf("load: %w", read),
+		spec: ChainSpec{
+			{Code: ErrCodeNotDefined, Message: "load"},
+			{Code: ErrCodeNotDefined, Message: "read"},
+			{Code: ErrCodeNotDefined},
+		},
+	}, {
+		err: err,
+		spec: ChainSpec{
+			{Code: 409, Message: "create account"},
+			{Code: 500, Message: "insert"},
+			{Code: 409, Message: "timeout"},
+		},
+		want: "errors: chain does not match spec:\n" +
+			"  layer 0: ok code=409 message=\"create account\"\n" +
+			"  layer 1: got code=409 message=\"insert user\", want code=500 message containing \"insert\"\n" +
+			"  layer 2: got code=409 message=\"duplicate key\", want code=409 message containing \"timeout\"",
+	}, {
+		err: err,
+		spec: ChainSpec{
+			{Code: 409, Message: "create account"},
+		},
+		want: "errors: chain does not match spec:\n" +
+			"  layer 0: ok code=409 message=\"create account\"\n" +
+			"  layer 1: unexpected code=409 message=\"insert user\"\n" +
+			"  layer 2: unexpected code=409 message=\"duplicate key\"",
+	}, {
+		err:  io.EOF,
+		spec: ChainSpec{{Code: ErrCodeNotDefined, Message: "EOF"}, {Code: 404}},
+		want: "errors: chain does not match spec:\n" +
+			"  layer 0: ok code=-1 message=\"EOF\"\n" +
+			"  layer 1: missing, want code=404 message containing \"\"",
+	}, {
+		err:  nil,
+		spec: nil,
+	}}
+
+	for i, tt := range tests {
+		got := MatchChain(tt.err, tt.spec)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("test %d: MatchChain(%v): got %v, want nil", i+1, tt.err, got)
+			}
+			continue
+		}
+		if got == nil || got.Error() != tt.want {
+			t.Errorf("test %d: MatchChain(%v):\n got: %v\nwant: %s", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestDiffChains(t *testing.T) {
+	root := New("duplicate key").SetCode(409)
+
+	tests := []struct {
+		a, b error
+		want string
+	}{{
+		a: nil, b: nil,
+		want: "",
+	}, {
+		a:    Wrap(WithMessage(root, "insert user"), "create account"),
+		b:    WithMessage(WithStack(WithMessage(root, "insert user")), "create account"),
+		want: "",
+	}, {
+		a: Wrap(WithMessage(root, "insert user"), "create account"),
+		b: Codef(500, WithMessage(root, "insert user"), "create account"),
+		want: "- 0: code=409 \"create account\"\n" +
+			"+ 0: code=500 \"create account\"\n" +
+			"  1: code=409 \"insert user\"\n" +
+			"  2: code=409 \"duplicate key\"",
+	}, {
+		a: Wrap(root, "create account"),
+		b: io.EOF,
+		want: "- 0: code=409 \"create account\"\n" +
+			"+ 0: code=-1 \"EOF\"\n" +
+			"- 1: code=409 \"duplicate key\"",
+	}}
+
+	for i, tt := range tests {
+		got := DiffChains(tt.a, tt.b)
+		if got != tt.want {
+			t.Errorf("test %d: DiffChains(%v, %v):\n got:\n%s\nwant:\n%s", i+1, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a, b error
+		want bool
+	}{
+		{nil, nil, true},
+		{nil, io.EOF, false},
+		{New("x"), nil, false},
+		{New("x"), New("x"), true},
+		{New("x").WithCode(404), New("x").WithCode(404), true},
+		{New("x").WithCode(404), New("y").WithCode(404), false},
+		{New("x").WithCode(404), New("x").WithCode(500), false},
+		{New("x").WithCode(404), New("x"), false},
+		{Wrap(New("x").WithCode(404), "read"), WithMessage(NewWithCode(404, "x"), "read"), true},
+		{io.EOF, New("EOF"), true},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.want {
+			t.Errorf("test %d: Equal(%v, %v): got %t, want %t", i+1, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package errors
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WithPublicMessage annotates err with a message that is safe to show to
+// clients. The public message does not change the result of Error.
+// If err is nil, WithPublicMessage returns nil.
+func WithPublicMessage(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	return &withPublicMessage{annotation{err}, message}
+}
+
+type withPublicMessage struct {
+	annotation
+	msg string
+}
+
+// PublicMessage returns the message attached to err by WithPublicMessage.
+// The outermost public message in the chain wins.
+func PublicMessage(err error) (string, bool) {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if w, ok := err.(*withPublicMessage); ok {
+			return w.msg, true
+		}
+	}
+	return "", false
+}
+
+// WithPublicField annotates err with a key/value pair which is safe to show
+// to clients, such as the name of an invalid argument. Public fields are
+// kept apart from the fields returned by Fields, which are internal.
+// If err is nil, WithPublicField returns nil.
+func WithPublicField(err error, key string, value interface{}) error {
+	if err == nil {
+		return nil
+	}
+	return &withPublicField{annotation{err}, key, value}
+}
+
+type withPublicField struct {
+	annotation
+	key   string
+	value interface{}
+}
+
+// PublicFields returns the fields attached to the errors in err's chain by
+// WithPublicField, merged into a single map. When the same key is attached
+// more than once, the outermost value wins. PublicFields returns nil if no
+// public fields are attached.
+func PublicFields(err error) map[string]interface{} {
+	var fields map[string]interface{}
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		w, ok := err.(*withPublicField)
+		if !ok {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{})
+		}
+		if _, ok := fields[w.key]; !ok {
+			fields[w.key] = w.value
+		}
+	}
+	return fields
+}
+
+// WithSuggestion annotates err with a suggested remedy for the user, such as
+// "check your API key". The suggestion is meant for display to end users
+// and is kept apart from the message.
+// If err is nil, WithSuggestion returns nil.
+func WithSuggestion(err error, suggestion string) error {
+	if err == nil {
+		return nil
+	}
+	return &withSuggestion{annotation{err}, suggestion}
+}
+
+type withSuggestion struct {
+	annotation
+	suggestion string
+}
+
+// Suggestion returns the suggestion attached to err by WithSuggestion.
+// The outermost suggestion in the chain wins.
+func Suggestion(err error) (string, bool) {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if w, ok := err.(*withSuggestion); ok {
+			return w.suggestion, true
+		}
+	}
+	return "", false
+}
+
+// WithBoundary marks err as the public boundary of its chain: PublicError
+// includes the message of err and of the errors wrapping it, but hides the
+// messages of the causes of err. The result of Error is unchanged, so the
+// full chain remains available for logging.
+// If err is nil, WithBoundary returns nil.
+func WithBoundary(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &withBoundary{annotation{err}}
+}
+
+type withBoundary struct {
+	annotation
+}
+
+// PublicError returns the message of err up to and including the outermost
+// public boundary set with WithBoundary: the messages added by the layers at
+// and above the boundary, joined as by Error. The messages of the causes
+// below the boundary are omitted. If err has no boundary, PublicError
+// returns err.Error(). If err is nil, it returns the empty string.
+func PublicError(err error) string {
+	if err == nil {
+		return ""
+	}
+	var msgs []string
+	boundary := false
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		if _, ok := e.(*withBoundary); ok {
+			boundary = true
+			continue
+		}
+		if msg, ok := localMessage(e); ok {
+			msgs = append(msgs, msg)
+			if boundary {
+				return strings.Join(msgs, causeSeparator())
+			}
+		}
+	}
+	return err.Error()
+}
+
+// ContextMessage returns the messages added by the wrappers in err's
+// chain, from outermost to innermost, joined with the separator set by
+// SetCauseArrow, ": " by default. The message of the root cause is
+// excluded, so that
+//
+//	ContextMessage(Wrap(Wrap(io.EOF, "read config"), "start server"))
+//
+// returns "start server: read config". Layers which add no message, such as
+// those created by WithStack, are skipped. If err is nil or has no wrappers,
+// ContextMessage returns "".
+func ContextMessage(err error) string {
+	var msgs []string
+	for i := 0; err != nil && i < maxDepth; i++ {
+		cause := next(err)
+		if cause == nil {
+			break
+		}
+		if msg, ok := localMessage(err); ok {
+			msgs = append(msgs, msg)
+		}
+		err = cause
+	}
+	return strings.Join(msgs, causeSeparator())
+}
+
+// SafeMessage returns the messages added by this package's errors in err's
+// chain, from outermost to innermost, joined with the separator set by
+// SetCauseSeparator, ": " by default. The walk stops at the first error not
+// defined by this package, whose message, which may contain secrets such as
+// connection strings, is excluded, so that
+//
+//	SafeMessage(Wrap(Wrap(dbErr, "query users"), "list users"))
+//
+// returns "list users: query users" whatever the message of dbErr. Layers
+// which add no message, such as those created by WithStack, are skipped.
+// If err is nil or is itself a foreign error, SafeMessage returns "".
+func SafeMessage(err error) string {
+	var msgs []string
+	for i := 0; err != nil && i < maxDepth && isOwn(err); i, err = i+1, next(err) {
+		switch e := err.(type) {
+		case *MsgCodeErr:
+			msgs = append(msgs, e.msg)
+		case *CauseMsgCodeError:
+			msgs = append(msgs, e.message())
+		}
+	}
+	return strings.Join(msgs, causeSeparator())
+}
+
+// WithReferenceID annotates err with a short random identifier, such as
+// "K3V9QX2M", which users can quote to support staff to find the error in
+// the logs. The identifier is included in the bodies written by
+// HTTPResponse and GraphQLExtensions, for clients, and in the output of
+// Report, Logfmt, the %+v verb and json.Marshal, for the internal logs. The
+// result of Error is unchanged. If err already has a reference ID,
+// WithReferenceID returns err unchanged.
+//
+// Identifiers are 8 characters of base32 encoding 40 random bits from
+// crypto/rand, which makes collisions unlikely among the errors of any
+// realistic support window. WithReferenceID is safe for concurrent use.
+// If err is nil, WithReferenceID returns nil.
+func WithReferenceID(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := ReferenceID(err); ok {
+		return err
+	}
+	var b [5]byte
+	if _, rerr := rand.Read(b[:]); rerr != nil {
+		return err
+	}
+	return &withReferenceID{annotation{err}, base32.StdEncoding.EncodeToString(b[:])}
+}
+
+type withReferenceID struct {
+	annotation
+	id string
+}
+
+// Format implements fmt.Formatter. The %+v verb prints the error followed
+// by a line showing the reference ID, as in "[ref=K3V9QX2M]".
+func (w *withReferenceID) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		formatCause(s, w.error)
+		_, _ = fmt.Fprintf(s, "\n[ref=%s]", w.id)
+		return
+	}
+	w.annotation.Format(s, verb)
+}
+
+// MarshalJSON implements json.Marshaler. The reference ID is added under
+// "reference_id" to the JSON object encoding the wrapped error.
+func (w *withReferenceID) MarshalJSON() ([]byte, error) {
+	return w.marshalJSON(0)
+}
+
+func (w *withReferenceID) marshalJSON(depth int) ([]byte, error) {
+	b, err := marshalError(w.error, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	obj := make(map[string]interface{})
+	if json.Unmarshal(b, &obj) != nil {
+		obj = map[string]interface{}{"message": w.Error()}
+	}
+	obj["reference_id"] = w.id
+	return json.Marshal(obj)
+}
+
+// ReferenceID returns the identifier attached to err by WithReferenceID.
+func ReferenceID(err error) (string, bool) {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if w, ok := err.(*withReferenceID); ok {
+			return w.id, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,192 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestPublicFields(t *testing.T) {
+	if got := WithPublicField(nil, "k", "v"); got != nil {
+		t.Errorf("WithPublicField(nil, \"k\", \"v\"): got %#v, expected nil", got)
+	}
+
+	tests := []struct {
+		err  error
+		want map[string]interface{}
+	}{
+		{nil, nil},
+		{io.EOF, nil},
+		{WithPublicField(io.EOF, "arg", "name"), map[string]interface{}{"arg": "name"}},
+		{
+			WithPublicField(Wrap(WithPublicField(WithPublicField(io.EOF, "arg", "name"), "limit", 10), "wrapped"), "arg", "email"),
+			map[string]interface{}{"arg": "email", "limit": 10},
+		},
+	}
+
+	for i, tt := range tests {
+		got := PublicFields(tt.err)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: PublicFields(%v): got %v, want %v", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestSuggestion(t *testing.T) {
+	if got := WithSuggestion(nil, "retry"); got != nil {
+		t.Errorf("WithSuggestion(nil, \"retry\"): got %#v, expected nil", got)
+	}
+
+	tests := []struct {
+		err    error
+		want   string
+		wantOK bool
+	}{
+		{nil, "", false},
+		{io.EOF, "", false},
+		{Wrap(WithSuggestion(io.EOF, "check your API key"), "call api"), "check your API key", true},
+		{WithSuggestion(Wrap(WithSuggestion(io.EOF, "inner"), "wrapped"), "outer"), "outer", true},
+	}
+
+	for i, tt := range tests {
+		got, ok := Suggestion(tt.err)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("test %d: Suggestion(%v): got (%q, %t), want (%q, %t)", i+1, tt.err, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPublicError(t *testing.T) {
+	if got := WithBoundary(nil); got != nil {
+		t.Errorf("WithBoundary(nil): got %#v, expected nil", got)
+	}
+
+	secret := New("dial tcp 10.0.0.7:5432: connection refused")
+	self := &cyclic{}
+	self.cause = self
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{io.EOF, "EOF"},
+		{Wrap(secret, "load user"), "load user: dial tcp 10.0.0.7:5432: connection refused"},
+		{Wrap(WithBoundary(Wrap(secret, "load user")), "handle request"), "handle request: load user"},
+		{WithBoundary(Wrap(secret, "load user")), "load user"},
+		{WithBoundary(WithStack(WithMessage(secret, "load user"))), "load user"},
+		{Wrap(WithBoundary(Wrap(WithBoundary(secret), "inner")), "outer"), "outer: inner"},
+		{self, "cyclic"},
+		{WithBoundary(self), "cyclic"},
+	}
+
+	for i, tt := range tests {
+		got := PublicError(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: PublicError(%v): got %q, want %q", i+1, tt.err, got, tt.want)
+		}
+		if tt.err != nil && !strings.Contains(tt.err.Error(), got) {
+			t.Errorf("test %d: Error() = %q does not contain PublicError %q", i+1, tt.err.Error(), got)
+		}
+	}
+}
+
+func TestContextMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{io.EOF, ""},
+		{New("root"), ""},
+		{WithStack(io.EOF), ""},
+		{Wrap(io.EOF, "read config"), "read config"},
+		{Wrap(Wrap(io.EOF, "read config"), "start server"), "start server: read config"},
+		{WithMessage(WithStack(Wrap(New("root"), "inner")), "outer"), "outer: inner"},
+		{WithHTTPStatus(Wrap(io.EOF, "read config"), 503), "read config"},
+	}
+
+	for i, tt := range tests {
+		got := ContextMessage(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: ContextMessage(%v): got %q, want %q", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReferenceID(t *testing.T) {
+	if got := WithReferenceID(nil); got != nil {
+		t.Errorf("WithReferenceID(nil): got %#v, expected nil", got)
+	}
+	if _, ok := ReferenceID(io.EOF); ok {
+		t.Errorf("ReferenceID(io.EOF): got true, want false")
+	}
+
+	err := WithReferenceID(io.EOF)
+	id, ok := ReferenceID(Wrap(err, "wrapped"))
+	if !ok || !regexp.MustCompile(`^[A-Z2-7]{8}$`).MatchString(id) {
+		t.Fatalf("ReferenceID(Wrap(WithReferenceID(io.EOF))): got (%q, %t), want an 8 character base32 id", id, ok)
+	}
+	if again := WithReferenceID(err); again != err {
+		t.Errorf("WithReferenceID on an error with an id: got %#v, want it unchanged", again)
+	}
+	if other, _ := ReferenceID(WithReferenceID(io.EOF)); other == id {
+		t.Errorf("WithReferenceID: got the same id %q twice", id)
+	}
+
+	status, body := HTTPResponse(err)
+	if want := `{"code":-1,"message":"Internal Server Error","reference_id":"` + id + `"}`; status != 500 || string(body) != want {
+		t.Errorf("HTTPResponse(%v): got (%d, %s), want (500, %s)", err, status, body, want)
+	}
+	if got := GraphQLExtensions(err)["referenceId"]; got != id {
+		t.Errorf("GraphQLExtensions(%v)[\"referenceId\"]: got %v, want %q", err, got, id)
+	}
+	if got := Report(err); !strings.Contains(got, "reference id: "+id) {
+		t.Errorf("Report(%v): missing reference id in:\n%s", err, got)
+	}
+	if got := fmt.Sprintf("%+v", Wrap(err, "wrapped")); !strings.Contains(got, "EOF\n[ref="+id+"]\n") {
+		t.Errorf("%%+v: missing reference id in:\n%s", got)
+	}
+	if got := err.Error(); got != "EOF" {
+		t.Errorf("Error: got %q, want %q", got, "EOF")
+	}
+	if got, _ := json.Marshal(Wrap(err, "wrapped")); !strings.Contains(string(got), `"reference_id":"`+id+`"`) {
+		t.Errorf("json.Marshal: missing reference id in %s", got)
+	}
+	if got := Logfmt(err); !strings.Contains(got, " ref="+id) {
+		t.Errorf("Logfmt: missing reference id in %s", got)
+	}
+}
+
+func TestSafeMessage(t *testing.T) {
+	const secret = "password=hunter2"
+	dbErr := fmt.Errorf("dial postgres://app:%s@db", secret)
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{io.EOF, ""},
+		{dbErr, ""},
+		{New("root"), "root"},
+		{Wrap(dbErr, "query users"), "query users"},
+		{Wrap(Wrap(dbErr, "query users"), "list users"), "list users: query users"},
+		{WithMessage(WithStack(Wrap(New("root"), "inner")), "outer"), "outer: inner: root"},
+		{WithHTTPStatus(Wrap(dbErr, "query users"), 503), "query users"},
+		{Wrap(fmt.Errorf("driver: %w", New("inner")), "outer"), "outer"},
+	}
+
+	for i, tt := range tests {
+		got := SafeMessage(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: SafeMessage(%v): got %q, want %q", i+1, tt.err, got, tt.want)
+		}
+		if strings.Contains(got, secret) {
+			t.Errorf("test %d: SafeMessage(%v): got %q, which contains the secret", i+1, tt.err, got)
+		}
+	}
+}
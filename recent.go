@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// recentEnabled is non-zero when the recent buffer is enabled. It lets
+// recordRecent skip locking in the common, disabled case.
+var recentEnabled int32
+
+// recent is the ring buffer behind SetRecentBuffer and RecentErrors.
+var recent struct {
+	sync.Mutex
+	buf  []error
+	next int
+	full bool
+}
+
+func init() {
+	newHooks = append(newHooks, recordRecent)
+}
+
+// SetRecentBuffer makes the package retain the last n errors created by its
+// constructors, such as New and Errorf, for retrieval with RecentErrors. A size of zero or less
+// disables the buffer, which is the default, and discards its contents.
+//
+// The buffer holds references to the retained errors, and therefore to
+// everything they reference, until they are pushed out by newer errors or
+// the buffer is disabled. Keep n small.
+//
+// It is safe to call SetRecentBuffer concurrently with the creation of
+// errors; resizing the buffer discards its contents.
+func SetRecentBuffer(n int) {
+	recent.Lock()
+	defer recent.Unlock()
+	recent.buf = nil
+	atomic.StoreInt32(&recentEnabled, 0)
+	if n > 0 {
+		recent.buf = make([]error, n)
+		atomic.StoreInt32(&recentEnabled, 1)
+	}
+	recent.next = 0
+	recent.full = false
+}
+
+// RecentErrors returns the errors retained by the buffer configured with
+// SetRecentBuffer, from oldest to newest. It returns nil if the buffer is
+// disabled.
+func RecentErrors() []error {
+	recent.Lock()
+	defer recent.Unlock()
+	if recent.buf == nil {
+		return nil
+	}
+	if !recent.full {
+		return append([]error(nil), recent.buf[:recent.next]...)
+	}
+	errs := make([]error, 0, len(recent.buf))
+	errs = append(errs, recent.buf[recent.next:]...)
+	return append(errs, recent.buf[:recent.next]...)
+}
+
+func recordRecent(err error) {
+	if atomic.LoadInt32(&recentEnabled) == 0 {
+		return
+	}
+	recent.Lock()
+	defer recent.Unlock()
+	if recent.buf == nil {
+		return
+	}
+	recent.buf[recent.next] = err
+	recent.next++
+	if recent.next == len(recent.buf) {
+		recent.next = 0
+		recent.full = true
+	}
+}
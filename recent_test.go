@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestRecentErrorsDisabled(t *testing.T) {
+	New("not retained")
+	if got := RecentErrors(); got != nil {
+		t.Errorf("RecentErrors(): got %v, want nil", got)
+	}
+}
+
+func TestRecentErrors(t *testing.T) {
+	SetRecentBuffer(2)
+	defer SetRecentBuffer(0)
+
+	a := New("a")
+	if got, want := RecentErrors(), []error{a}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecentErrors(): got %v, want %v", got, want)
+	}
+
+	b := Errorf("%s", "b")
+	c := New("c")
+	if got, want := RecentErrors(), []error{b, c}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RecentErrors(): got %v, want %v", got, want)
+	}
+
+	SetRecentBuffer(0)
+	if got := RecentErrors(); got != nil {
+		t.Errorf("RecentErrors() after disabling: got %v, want nil", got)
+	}
+}
+
+func TestRecentErrorsConcurrent(t *testing.T) {
+	SetRecentBuffer(8)
+	defer SetRecentBuffer(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				New("concurrent")
+				RecentErrors()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(RecentErrors()); got != 8 {
+		t.Errorf("len(RecentErrors()): got %d, want 8", got)
+	}
+}
@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Recover converts r, a value returned by the built-in recover, into an
+// error annotated with the message "panic" and a stack trace. If r is an
+// error it is wrapped, keeping its code; any other value is formatted with
+// %v. If r is nil, Recover returns nil, so that it can be used as
+//
+//	defer func() {
+//	        if err := errors.Recover(recover()); err != nil {
+//	                ...
+//	        }
+//	}()
+//
+// Recover must be called from the deferred function. The stack trace is
+// recorded there, but the frames of the deferred function and of the
+// runtime's panic machinery are omitted, so that the trace starts where
+// the panic occurred.
+func Recover(r interface{}) *StackError {
+	if r == nil {
+		return nil
+	}
+	err, ok := r.(error)
+	if !ok {
+		leaf := &MsgCodeErr{
+			msg:    fmt.Sprintf("%v", r),
+			code:   ErrCodeNotDefined,
+			fields: scopeFields(),
+		}
+		onNew(leaf)
+		err = leaf
+	}
+	cErr := annotate(err, "panic")
+	st := callersSkip(cErr, 0)
+	if st != nil {
+		*st = trimPanic(*st)
+	}
+	return &StackError{
+		error:  cErr,
+		stack:  st,
+		fields: scopeFields(),
+	}
+}
+
+// trimPanic returns the frames of s below those of runtime.gopanic and of
+// any runtime function it calls on behalf of the panicking function. s is
+// returned unchanged if it does not contain runtime.gopanic.
+func trimPanic(s stack) stack {
+	for i, pc := range s {
+		if Frame(pc).name() != "runtime.gopanic" {
+			continue
+		}
+		for i++; i < len(s) && strings.HasPrefix(Frame(s[i]).name(), "runtime."); i++ {
+		}
+		return s[i:]
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"io"
+	"testing"
+)
+
+//go:noinline
+func panicWith(v interface{}) (err *StackError) {
+	defer func() {
+		err = Recover(recover())
+	}()
+	panic(v)
+}
+
+func TestRecover(t *testing.T) {
+	if got := Recover(nil); got != nil {
+		t.Errorf("Recover(nil): got %#v, expected nil", got)
+	}
+
+	conflict := NewWithCode(409, "conflict")
+	tests := []struct {
+		value    interface{}
+		wantMsg  string
+		wantCode int
+	}{
+		{"boom", "panic: boom", ErrCodeNotDefined},
+		{42, "panic: 42", ErrCodeNotDefined},
+		{io.EOF, "panic: EOF", ErrCodeNotDefined},
+		{conflict, "panic: conflict", 409},
+	}
+
+	for i, tt := range tests {
+		err := panicWith(tt.value)
+		if err == nil {
+			t.Errorf("test %d: Recover(%v): got nil", i+1, tt.value)
+			continue
+		}
+		if got := err.Error(); got != tt.wantMsg {
+			t.Errorf("test %d: Error(): got %q, want %q", i+1, got, tt.wantMsg)
+		}
+		if got := err.Code(); got != tt.wantCode {
+			t.Errorf("test %d: Code(): got %d, want %d", i+1, got, tt.wantCode)
+		}
+		if e, ok := tt.value.(error); ok && !Is(err, e) {
+			t.Errorf("test %d: Is(%v, %v): got false, want true", i+1, err, e)
+		}
+		if f, ok := err.StackTrace().TopFrame(); !ok || f.Function() != "github.com/WeiquanWa/errors.panicWith" {
+			t.Errorf("test %d: got top frame %+v, want panicWith", i+1, f)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package errors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Report returns a multi-line, human-readable description of everything
+// the package knows about err, intended for bug reports and debug
+// endpoints. It contains the full message, the structure of the chain as
+// rendered by Tree, and then, for each link of the chain from outermost to
+// innermost, its type, message, code, fields, metadata and stack trace. The
+// innermost stack trace, recording where the error originated, is marked
+// as the origin.
+//
+// The format of Report is meant for people and may change; use the
+// Format verbs or Layers for machine-readable output.
+// If err is nil, Report returns the empty string.
+func Report(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	var layers []error
+	origin := -1
+	for e := err; e != nil && len(layers) < maxDepth; e = next(e) {
+		if st, ok := e.(interface{ StackTrace() StackTrace }); ok && len(st.StackTrace()) > 0 {
+			origin = len(layers)
+		}
+		layers = append(layers, e)
+	}
+
+	var b strings.Builder
+	b.WriteString("error: " + err.Error() + "\n")
+	b.WriteString("\ntree:\n")
+	b.WriteString(indent(Tree(err), "  ") + "\n")
+	b.WriteString("\nlayers:\n")
+	for i, e := range layers {
+		fmt.Fprintf(&b, "  [%d] %T", i, e)
+		if i == origin {
+			b.WriteString(" (origin)")
+		}
+		b.WriteByte('\n')
+		if msg, ok := localMessage(e); ok {
+			b.WriteString("      message: " + msg + "\n")
+		}
+		if cErr, ok := e.(interface{ Code() int }); ok && cErr.Code() != ErrCodeNotDefined {
+			b.WriteString("      code: " + strconv.Itoa(cErr.Code()))
+			if name, ok := registeredCodeName(cErr.Code()); ok {
+				b.WriteString(" (" + name + ")")
+			}
+			b.WriteByte('\n')
+		}
+		if w, ok := e.(*CauseMsgCodeError); ok && w.causeCode != w.Code() {
+			b.WriteString("      cause code: " + strconv.Itoa(w.causeCode) + "\n")
+		}
+		fields := localFields(e)
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "      field: %s=%v\n", k, fields[k])
+		}
+		switch e := e.(type) {
+		case *MsgCodeErr:
+			if e.expected {
+				b.WriteString("      expected: true\n")
+			}
+			if e.temporary {
+				b.WriteString("      temporary: true\n")
+			}
+		case *withHTTPStatus:
+			b.WriteString("      http status: " + strconv.Itoa(e.status) + "\n")
+		case *withPublicMessage:
+			b.WriteString("      public message: " + e.msg + "\n")
+		case *withPublicField:
+			fmt.Fprintf(&b, "      public field: %s=%v\n", e.key, e.value)
+		case *withSuggestion:
+			b.WriteString("      suggestion: " + e.suggestion + "\n")
+		case *withSeverity:
+			b.WriteString("      severity: " + e.severity.String() + "\n")
+		case *withReferenceID:
+			b.WriteString("      reference id: " + e.id + "\n")
+		case *withLogged:
+			b.WriteString("      logged: true\n")
+		case *withExpected:
+			b.WriteString("      expected: true\n")
+		case *withTemporary:
+			b.WriteString("      temporary: " + strconv.FormatBool(e.temporary) + "\n")
+		case *withBoundary:
+			b.WriteString("      public boundary: true\n")
+		case *withExitCode:
+			b.WriteString("      exit code: " + strconv.Itoa(e.exit) + "\n")
+		case *withBuildInfo:
+			b.WriteString("      build: " + e.info.Main.Path + " " + e.info.Main.Version + "\n")
+			readBuildInfo()
+			if rev := buildInfo.json.Revision; rev != "" {
+				b.WriteString("      build revision: " + rev + "\n")
+			}
+		}
+		if st, ok := e.(interface{ StackTrace() StackTrace }); ok && len(st.StackTrace()) > 0 {
+			b.WriteString("      stack:")
+			b.WriteString(strings.Replace(fmt.Sprintf("%+v", st.StackTrace()), "\n", "\n        ", -1))
+			b.WriteByte('\n')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Message returns the message added by the outermost layer of err's chain
+// which adds one, without the messages of its causes. Layers which add no
+// message, such as those created by WithStack and WithHTTPStatus, are
+// skipped. If err is nil, Message returns "".
+func Message(err error) string {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if msg, ok := localMessage(err); ok {
+			return msg
+		}
+	}
+	return ""
+}
+
+// localMessage returns the message added by err itself, excluding the
+// message of its cause. It returns false for layers, such as those created
+// by WithStack, which add no message.
+func localMessage(err error) (string, bool) {
+	switch e := err.(type) {
+	case *MsgCodeErr:
+		return e.msg, true
+	case *CauseMsgCodeError:
+		return e.message(), true
+	}
+	cause := next(err)
+	if cause == nil {
+		return err.Error(), true
+	}
+	if cause.Error() == err.Error() {
+		return "", false
+	}
+	return treeLabel(err, cause), true
+}
+
+// indent prefixes every line of s with prefix.
+func indent(s, prefix string) string {
+	return prefix + strings.Replace(s, "\n", "\n"+prefix, -1)
+}
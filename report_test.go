@@ -0,0 +1,110 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Registered once, as in codes_test.go, so that the tests can rerun.
+func init() { RegisterCode(-2001, "TEST_REPORT") }
+
+func TestReportNil(t *testing.T) {
+	if got := Report(nil); got != "" {
+		t.Errorf("Report(nil): got %q, want %q", got, "")
+	}
+}
+
+func TestReport(t *testing.T) {
+	inner := New("row missing").SetCode(-2001)
+	err := Wrap(WithSuggestion(WithHTTPStatus(WithMessage(inner, "find user"), http.StatusNotFound), "check the id"), "handle request")
+
+	got := Report(err)
+	for _, want := range []string{
+		"error: handle request: find user: row missing\n",
+		"\ntree:\n  handle request [code=-2001]\n",
+		"\nlayers:\n  [0] *errors.StackError\n",
+		"  [1] *errors.CauseMsgCodeError\n      message: handle request\n      code: -2001 (TEST_REPORT)\n",
+		"      http status: 404\n",
+		"      suggestion: check the id\n",
+		"*errors.MsgCodeErr (origin)\n      message: row missing\n",
+		"      stack:\n        github.com/WeiquanWa/errors.TestReport\n",
+		"report_test.go:",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Report: missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "(origin)") != 1 {
+		t.Errorf("Report: want exactly one origin in:\n%s", got)
+	}
+}
+
+func TestReportForeign(t *testing.T) {
+	got := Report(WithStack(io.EOF))
+	for _, want := range []string{
+		"  [0] *errors.StackError (origin)\n",
+		"  [1] *errors.errorString\n      message: EOF",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Report: missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	root := New("connection refused")
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{io.EOF, "EOF"},
+		{root, "connection refused"},
+		{Wrap(root, "query users"), "query users"},
+		{WithMessage(Wrap(root, "query users"), "load page"), "load page"},
+		{WithHTTPStatus(WithStack(Wrap(root, "query users")), 503), "query users"},
+		{fmt.Errorf("dial: %w", io.EOF), "dial"},
+	}
+
+	for i, tt := range tests {
+		if got := Message(tt.err); got != tt.want {
+			t.Errorf("test %d: Message(%v): got %q, want %q", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	if got := WithMessage(root, "outer").Message(); got != "outer" {
+		t.Errorf("CauseMsgCodeError.Message(): got %q, want %q", got, "outer")
+	}
+	if got := root.Message(); got != "connection refused" {
+		t.Errorf("MsgCodeErr.Message(): got %q, want %q", got, "connection refused")
+	}
+}
+
+func TestReportMetadata(t *testing.T) {
+	pop := PushScope(map[string]interface{}{"request_id": "r1"})
+	inner := NewTemporary("busy")
+	pop()
+	err := WithExitCode(WithBoundary(WithTemporary(WithField(Wrap(WithExpected(inner), "call"), "attempt", 3), false)), 75)
+	err = WithBuildInfo(err)
+
+	got := Report(err)
+	want := []string{
+		"      exit code: 75\n",
+		"      public boundary: true\n",
+		"      temporary: false\n",
+		"      field: attempt=3\n",
+		"      expected: true\n",
+		"*errors.MsgCodeErr (origin)\n      message: busy\n      field: request_id=r1\n      temporary: true\n",
+	}
+	if _, ok := BuildInfo(err); ok {
+		want = append(want, "      build: ")
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("Report: missing %q in:\n%s", w, got)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package errors
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// activeScopes counts the scopes pushed and not yet popped, letting
+// scopeFields return immediately in the common case where there are none.
+var activeScopes int32
+
+// scopes holds the scopeStack of each goroutine with active scopes, keyed
+// by goroutine id. A sync.Map keeps goroutines creating errors from
+// contending on a lock shared by the whole process.
+var scopes sync.Map
+
+// scopeStack holds the active scopes of one goroutine, innermost last. Only
+// that goroutine pushes and pops them; the mutex keeps a pop function
+// called from elsewhere from corrupting the stack.
+type scopeStack struct {
+	sync.Mutex
+	id      uint64
+	entries []*scope
+}
+
+// scope is a pushed scope. Its address identifies it to its pop function.
+type scope struct {
+	// fields holds the fields of this scope merged over those of the
+	// scopes enclosing it.
+	fields map[string]interface{}
+}
+
+// PushScope makes the supplied fields defaults for every error created by
+// the calling goroutine until the returned pop function is called. The
+// fields of active scopes are merged into the Fields of errors created by
+// New, Errorf, NewCode and every function which records a stack trace while
+// wrapping, such as Wrap and WithStack.
+//
+// Scopes are goroutine-local: errors created by other goroutines, including
+// goroutines started inside the scope, do not receive its fields. Scopes
+// nest; when a key is set by several active scopes the innermost value wins.
+// The pop function must be called on the goroutine which called PushScope,
+// usually by deferring it. Popping a scope also pops any scopes pushed
+// inside it which were not popped. Calling pop more than once, or after the
+// scope was popped with an enclosing one, has no effect.
+//
+// The fields map is copied; later changes to it do not affect the scope.
+// Go has no goroutine-local storage, so scopes identify goroutines by
+// parsing runtime.Stack, which costs around a microsecond per error created
+// while any goroutine has an active scope. Prefer passing values explicitly
+// where possible, and pop every scope pushed.
+func PushScope(fields map[string]interface{}) (pop func()) {
+	id := goid()
+	v, _ := scopes.LoadOrStore(id, &scopeStack{id: id})
+	stack := v.(*scopeStack)
+
+	stack.Lock()
+	merged := make(map[string]interface{})
+	if n := len(stack.entries); n > 0 {
+		for k, v := range stack.entries[n-1].fields {
+			merged[k] = v
+		}
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	sc := &scope{fields: merged}
+	stack.entries = append(stack.entries, sc)
+	stack.Unlock()
+	atomic.AddInt32(&activeScopes, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { stack.pop(sc) })
+	}
+}
+
+// pop removes sc and the scopes pushed after it from the stack. It does
+// nothing if sc is no longer on the stack.
+func (st *scopeStack) pop(sc *scope) {
+	st.Lock()
+	defer st.Unlock()
+	for i, e := range st.entries {
+		if e != sc {
+			continue
+		}
+		atomic.AddInt32(&activeScopes, -int32(len(st.entries)-i))
+		for j := i; j < len(st.entries); j++ {
+			st.entries[j] = nil
+		}
+		st.entries = st.entries[:i]
+		if i == 0 {
+			scopes.Delete(st.id)
+		}
+		return
+	}
+}
+
+// scopeFields returns the merged fields of the calling goroutine's active
+// scopes, or nil if it has none.
+func scopeFields() map[string]interface{} {
+	if atomic.LoadInt32(&activeScopes) == 0 {
+		return nil
+	}
+	v, ok := scopes.Load(goid())
+	if !ok {
+		return nil
+	}
+	stack := v.(*scopeStack)
+
+	stack.Lock()
+	defer stack.Unlock()
+	n := len(stack.entries)
+	if n == 0 {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(stack.entries[n-1].fields))
+	for k, v := range stack.entries[n-1].fields {
+		fields[k] = v
+	}
+	return fields
+}
+
+// goid returns the id of the calling goroutine, parsed from the header
+// "goroutine <id> [<state>]:" written by runtime.Stack.
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
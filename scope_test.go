@@ -0,0 +1,87 @@
+package errors
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestPushScope(t *testing.T) {
+	if got := Fields(New("no scope")); got != nil {
+		t.Errorf("Fields(New(...)) without scope: got %v, want nil", got)
+	}
+
+	popOuter := PushScope(map[string]interface{}{"requestID": "r1", "userID": 7})
+	outer := New("outer scope")
+
+	popInner := PushScope(map[string]interface{}{"userID": 8, "step": "load"})
+	inner := Wrap(io.EOF, "inner scope")
+	popInner()
+	popInner()
+
+	after := WithStack(io.EOF)
+	popOuter()
+	popped := New("popped")
+
+	tests := []struct {
+		err  error
+		want map[string]interface{}
+	}{
+		{outer, map[string]interface{}{"requestID": "r1", "userID": 7}},
+		{inner, map[string]interface{}{"requestID": "r1", "userID": 8, "step": "load"}},
+		{after, map[string]interface{}{"requestID": "r1", "userID": 7}},
+		{popped, nil},
+	}
+
+	for i, tt := range tests {
+		if got := Fields(tt.err); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("test %d: Fields(%v): got %v, want %v", i+1, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPushScopePopsNested(t *testing.T) {
+	pop := PushScope(map[string]interface{}{"a": 1})
+	PushScope(map[string]interface{}{"b": 2})
+	pop()
+
+	if got := Fields(New("x")); got != nil {
+		t.Errorf("Fields(New(...)) after popping outer scope: got %v, want nil", got)
+	}
+}
+
+func TestPushScopeGoroutineLocal(t *testing.T) {
+	defer PushScope(map[string]interface{}{"requestID": "r1"})()
+
+	errs := make(chan error)
+	go func() { errs <- New("other goroutine") }()
+	if got := Fields(<-errs); got != nil {
+		t.Errorf("Fields(New(...)) on another goroutine: got %v, want nil", got)
+	}
+}
+
+func TestFieldsOuterWins(t *testing.T) {
+	pop := PushScope(map[string]interface{}{"k": "inner"})
+	err := New("x")
+	pop()
+	pop = PushScope(map[string]interface{}{"k": "outer"})
+	wrapped := Wrap(err, "y")
+	pop()
+
+	if got, want := Fields(wrapped), map[string]interface{}{"k": "outer"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(%v): got %v, want %v", wrapped, got, want)
+	}
+}
+
+func TestPushScopeStalePop(t *testing.T) {
+	popA := PushScope(map[string]interface{}{"a": 1})
+	popB := PushScope(map[string]interface{}{"b": 2})
+	popA()
+	popC := PushScope(map[string]interface{}{"c": 3})
+	defer popC()
+	popB()
+
+	if got, want := Fields(New("x")), map[string]interface{}{"c": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields(New(...)) after a stale pop: got %v, want %v", got, want)
+	}
+}
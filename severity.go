@@ -0,0 +1,111 @@
+package errors
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Severity classifies how serious an error is, for logging and alerting.
+type Severity int
+
+// Severity levels, from least to most severe. The zero value, SeverityUnset,
+// means no severity has been assigned.
+const (
+	SeverityUnset Severity = iota
+	SeverityDebug
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+)
+
+var severityNames = [...]string{
+	SeverityUnset: "unset",
+	SeverityDebug: "debug",
+	SeverityInfo:  "info",
+	SeverityWarn:  "warn",
+	SeverityError: "error",
+	SeverityFatal: "fatal",
+}
+
+// String returns the lower case name of the severity.
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return "severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// WithSeverity annotates err with the severity s.
+// If err is nil, WithSeverity returns nil.
+func WithSeverity(err error, s Severity) error {
+	if err == nil {
+		return nil
+	}
+	return &withSeverity{annotation{err}, s}
+}
+
+type withSeverity struct {
+	annotation
+	severity Severity
+}
+
+// Format implements fmt.Formatter. The %+v verb prints the error followed
+// by a line showing the severity, as in "[severity=warn]".
+func (w *withSeverity) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') && w.severity != SeverityUnset {
+		formatCause(s, w.error)
+		_, _ = fmt.Fprintf(s, "\n[severity=%s]", w.severity)
+		return
+	}
+	w.annotation.Format(s, verb)
+}
+
+// codeSeverities holds the severities registered with SetSeverityForCode.
+var codeSeverities = struct {
+	sync.RWMutex
+	m map[int]Severity
+}{m: make(map[int]Severity)}
+
+// SetSeverityForCode makes s the severity of errors with the given code
+// which have no severity attached with WithSeverity. Passing SeverityUnset
+// removes the rule for code. It is safe for concurrent use, but is usually
+// called from init functions.
+func SetSeverityForCode(code int, s Severity) {
+	codeSeverities.Lock()
+	defer codeSeverities.Unlock()
+	if s == SeverityUnset {
+		delete(codeSeverities.m, code)
+		return
+	}
+	codeSeverities.m[code] = s
+}
+
+// GetSeverity returns the severity of err. The precedence is:
+//
+//  1. the outermost severity attached with WithSeverity;
+//  2. the severity set with SetSeverityForCode for the code of err, as
+//     found by GetCode;
+//  3. SeverityError.
+//
+// If err is nil, GetSeverity returns SeverityUnset.
+func GetSeverity(err error) Severity {
+	if err == nil {
+		return SeverityUnset
+	}
+	for i, e := 0, err; e != nil && i < maxDepth; i, e = i+1, next(e) {
+		if w, ok := e.(*withSeverity); ok && w.severity != SeverityUnset {
+			return w.severity
+		}
+	}
+	if code := GetCode(err); code != ErrCodeNotDefined {
+		codeSeverities.RLock()
+		s, ok := codeSeverities.m[code]
+		codeSeverities.RUnlock()
+		if ok {
+			return s
+		}
+	}
+	return SeverityError
+}
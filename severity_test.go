@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"testing"
+)
+
+func TestGetSeverity(t *testing.T) {
+	SetSeverityForCode(-5001, SeverityWarn)
+	SetSeverityForCode(-5002, SeverityFatal)
+	SetSeverityForCode(-5002, SeverityUnset)
+
+	tests := []struct {
+		err  error
+		want Severity
+	}{
+		{nil, SeverityUnset},
+		{io.EOF, SeverityError},
+		{New("x").SetCode(-5001), SeverityWarn},
+		{Wrap(New("x").SetCode(-5001), "wrapped"), SeverityWarn},
+		{fmt.Errorf("foreign: %w", New("x").SetCode(-5001)), SeverityWarn},
+		{New("x").SetCode(-5002), SeverityError},
+		{WithSeverity(New("x").SetCode(-5001), SeverityDebug), SeverityDebug},
+		{Wrap(WithSeverity(io.EOF, SeverityInfo), "wrapped"), SeverityInfo},
+		{WithSeverity(WithSeverity(io.EOF, SeverityInfo), SeverityFatal), SeverityFatal},
+	}
+
+	for i, tt := range tests {
+		got := GetSeverity(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: GetSeverity(%v): got %v, want %v", i+1, tt.err, got, tt.want)
+		}
+	}
+
+	if got := WithSeverity(nil, SeverityWarn); got != nil {
+		t.Errorf("WithSeverity(nil, SeverityWarn): got %#v, expected nil", got)
+	}
+}
+
+func TestSeverityString(t *testing.T) {
+	tests := []struct {
+		s    Severity
+		want string
+	}{
+		{SeverityUnset, "unset"},
+		{SeverityWarn, "warn"},
+		{SeverityFatal, "fatal"},
+		{Severity(42), "severity(42)"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("Severity(%d).String(): got %q, want %q", int(tt.s), got, tt.want)
+		}
+	}
+}
+
+func TestFormatSeverity(t *testing.T) {
+	leaf := WithSeverity(New("disk full"), SeverityWarn)
+	err := WithMessage(Wrap(leaf, "write block"), "flush")
+
+	if got := GetSeverity(err); got != SeverityWarn {
+		t.Errorf("GetSeverity(%v): got %v, want %v", err, got, SeverityWarn)
+	}
+
+	tests := []struct {
+		err    error
+		format string
+		want   string
+	}{
+		{leaf, "%s", "^disk full$"},
+		{leaf, "%v", "^disk full$"},
+		{leaf, "%+v", "^disk full\n(?s:.*)\n\\[severity=warn\\]$"},
+		{err, "%+v", "^disk full\n(?s:.*)\n\\[severity=warn\\]\n(?s:.*)write block\n(?s:.*)flush\n"},
+		{WithSeverity(io.EOF, SeverityUnset), "%+v", "^EOF$"},
+	}
+
+	for i, tt := range tests {
+		got := fmt.Sprintf(tt.format, tt.err)
+		if !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("test %d: Sprintf(%q, err):\ngot:\n%s\nwant match of:\n%s", i+1, tt.format, got, tt.want)
+		}
+	}
+}
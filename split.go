@@ -0,0 +1,41 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+)
+
+// FormatSplit writes err's message to msgW and its stack traces to stackW,
+// so that operators can route the verbose traces to a different
+// destination than the messages. The message is the result of Error
+// followed by a newline. Each stack trace recorded in the chain is written,
+// from outermost to innermost, as a "stack N:" header followed by its
+// frames as printed by %+v, with traces separated by a blank line. Nothing
+// is written to stackW if the chain records no stack trace.
+//
+// FormatSplit returns the first error returned by either writer. If err is
+// nil, FormatSplit writes nothing.
+func FormatSplit(err error, msgW, stackW io.Writer) error {
+	if err == nil {
+		return nil
+	}
+	if _, werr := io.WriteString(msgW, err.Error()+"\n"); werr != nil {
+		return werr
+	}
+	n := 0
+	for e := err; e != nil && n < maxDepth; e = next(e) {
+		st, ok := e.(interface{ StackTrace() StackTrace })
+		if !ok || len(st.StackTrace()) == 0 {
+			continue
+		}
+		sep := ""
+		if n > 0 {
+			sep = "\n"
+		}
+		n++
+		if _, werr := fmt.Fprintf(stackW, "%sstack %d:%+v\n", sep, n, st.StackTrace()); werr != nil {
+			return werr
+		}
+	}
+	return nil
+}
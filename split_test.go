@@ -0,0 +1,57 @@
+package errors
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormatSplit(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantMsg    string
+		wantStacks int
+	}{
+		{nil, "", 0},
+		{io.EOF, "EOF\n", 0},
+		{New("secret"), "secret\n", 1},
+		{Wrap(New("secret"), "outer"), "outer: secret\n", 2},
+		{WithHTTPStatus(Expected("miss"), 404), "miss\n", 0},
+	}
+
+	for i, tt := range tests {
+		var msg, stack bytes.Buffer
+		if err := FormatSplit(tt.err, &msg, &stack); err != nil {
+			t.Fatalf("test %d: FormatSplit: unexpected error %v", i+1, err)
+		}
+		if msg.String() != tt.wantMsg {
+			t.Errorf("test %d: FormatSplit(%v): got message %q, want %q", i+1, tt.err, msg.String(), tt.wantMsg)
+		}
+		if got := strings.Count(stack.String(), "stack "); got != tt.wantStacks {
+			t.Errorf("test %d: FormatSplit(%v): got %d stacks, want %d:\n%s", i+1, tt.err, got, tt.wantStacks, stack.String())
+		}
+		if strings.Contains(msg.String(), ".go:") {
+			t.Errorf("test %d: FormatSplit(%v): message %q contains a stack frame", i+1, tt.err, msg.String())
+		}
+		for _, word := range []string{"secret", "outer", "miss"} {
+			if strings.Contains(stack.String(), word) {
+				t.Errorf("test %d: FormatSplit(%v): stack output leaks %q:\n%s", i+1, tt.err, word, stack.String())
+			}
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
+
+func TestFormatSplitWriteError(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FormatSplit(New("boom"), failingWriter{}, &buf); err != io.ErrShortWrite {
+		t.Errorf("FormatSplit with failing message writer: got %v, want %v", err, io.ErrShortWrite)
+	}
+	if err := FormatSplit(New("boom"), &buf, failingWriter{}); err != io.ErrShortWrite {
+		t.Errorf("FormatSplit with failing stack writer: got %v, want %v", err, io.ErrShortWrite)
+	}
+}
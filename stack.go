@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"io"
 	"path"
+	"reflect"
+	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Frame represents a program counter inside a stack frame.
@@ -93,6 +97,28 @@ func (f Frame) MarshalText() ([]byte, error) {
 	return []byte(fmt.Sprintf("%s %s:%d", name, f.file(), f.line())), nil
 }
 
+// File returns the full path to the source file containing the frame's
+// function, or "" if the frame cannot be resolved.
+func (f Frame) File() string {
+	if runtime.FuncForPC(f.pc()) == nil {
+		return ""
+	}
+	return f.file()
+}
+
+// Line returns the source line of the frame, or 0 if the frame cannot be
+// resolved.
+func (f Frame) Line() int { return f.line() }
+
+// Function returns the fully qualified name of the frame's function, or ""
+// if the frame cannot be resolved.
+func (f Frame) Function() string {
+	if runtime.FuncForPC(f.pc()) == nil {
+		return ""
+	}
+	return f.name()
+}
+
 // StackTrace is stack of Frames from innermost (newest) to outermost (oldest).
 type StackTrace []Frame
 
@@ -123,6 +149,76 @@ func (st StackTrace) Format(s fmt.State, verb rune) {
 	}
 }
 
+// StringSlice returns one entry per Frame of st, formatted as by %+v:
+//
+//	function
+//		file:line
+//
+// with the function name and the full path of the source file separated by
+// a newline and a tab.
+func (st StackTrace) StringSlice() []string {
+	s := make([]string, len(st))
+	for i, f := range st {
+		s[i] = fmt.Sprintf("%+v", f)
+	}
+	return s
+}
+
+// TraceStrings returns the first stack trace in err's chain, as found by
+// GetStackTrace, formatted by StringSlice. It returns an empty slice if err
+// is nil or has no stack trace.
+func TraceStrings(err error) []string {
+	return GetStackTrace(err).StringSlice()
+}
+
+// Compact returns st on a single line, for log systems which handle one
+// line per entry: each Frame is formatted as by %v, as file:line, and the
+// frames are separated by " < ", innermost first.
+func (st StackTrace) Compact() string {
+	var b strings.Builder
+	for i, f := range st {
+		if i > 0 {
+			b.WriteString(" < ")
+		}
+		fmt.Fprintf(&b, "%v", f)
+	}
+	return b.String()
+}
+
+// CompactTrace returns the first stack trace in err's chain, as found by
+// GetStackTrace, formatted by Compact. It returns "" if err is nil or has
+// no stack trace.
+func CompactTrace(err error) string {
+	return GetStackTrace(err).Compact()
+}
+
+// TopFrame returns the innermost Frame of st, where the trace was
+// recorded, and true. It returns false if st is empty.
+func (st StackTrace) TopFrame() (Frame, bool) {
+	if len(st) == 0 {
+		return 0, false
+	}
+	return st[0], true
+}
+
+// OriginFrame returns the frame where err originated: the top frame of the
+// innermost non-empty stack trace in err's chain, walking it by Cause and
+// Unwrap. For an error created by New and then wrapped, this is the New
+// call site, whereas GetStackTrace returns the trace of the outermost
+// wrapper. It returns false if no error in the chain has a trace or err is
+// nil.
+func OriginFrame(err error) (Frame, bool) {
+	var origin StackTrace
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if st, ok := err.(interface{ StackTrace() StackTrace }); ok {
+			if trace := st.StackTrace(); len(trace) > 0 {
+				origin = trace
+			}
+		}
+	}
+	return origin.TopFrame()
+}
+
 // formatSlice will format this StackTrace into the given buffer as a slice of
 // Frame, only valid when called with '%s' or '%v'.
 func (st StackTrace) formatSlice(s fmt.State, verb rune) {
@@ -136,16 +232,31 @@ func (st StackTrace) formatSlice(s fmt.State, verb rune) {
 	io.WriteString(s, "]")
 }
 
+// lineNumber matches the line number following a Go source file name.
+var lineNumber = regexp.MustCompile(`(\.go):\d+`)
+
+// NormalizeStack replaces every line number following a Go source file name
+// in formatted, as produced by formatting an error or StackTrace, with N.
+// Output normalised in this way is stable across edits that move code, which
+// makes it suitable for golden tests that include stack traces.
+//
+// NormalizeStack is intended for use in tests, not in production code.
+func NormalizeStack(formatted string) string {
+	return lineNumber.ReplaceAllString(formatted, "$1:N")
+}
+
 // stack represents a stack of program counters.
 type stack []uintptr
 
 func (s *stack) Format(st fmt.State, verb rune) {
+	if s == nil {
+		return
+	}
 	switch verb {
 	case 'v':
 		switch {
 		case st.Flag('+'):
-			for _, pc := range *s {
-				f := Frame(pc)
+			for _, f := range s.StackTrace() {
 				fmt.Fprintf(st, "\n%+v", f)
 			}
 		}
@@ -153,18 +264,246 @@ func (s *stack) Format(st fmt.State, verb rune) {
 }
 
 func (s *stack) StackTrace() StackTrace {
-	f := make([]Frame, len(*s))
-	for i := 0; i < len(f); i++ {
-		f[i] = Frame((*s)[i])
+	if s == nil {
+		return nil
+	}
+	keep, _ := captureFilter.Load().(func(Frame) bool)
+	f := make([]Frame, 0, len(*s))
+	for _, pc := range *s {
+		if trimFrame(Frame(pc)) || keep != nil && !keep(Frame(pc)) {
+			continue
+		}
+		f = append(f, Frame(pc))
 	}
 	return f
 }
 
-func callers() *stack {
-	const depth = 32
-	var pcs [depth]uintptr
-	n := runtime.Callers(3, pcs[:])
-	var st stack = pcs[0:n]
+// trimPaths holds the []string of prefixes configured by SetTrimStackPaths.
+var trimPaths atomic.Value
+
+// pkgDir is the directory holding the source files of this package, with a
+// trailing slash.
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return path.Dir(file) + "/"
+}()
+
+// SetTrimStackPaths makes stack traces omit every frame whose source file
+// path starts with one of prefixes, such as the GOROOT directory, so that
+// traces show the caller's own code first. Frames are omitted when the
+// trace is retrieved with StackTrace or printed, not when it is recorded,
+// so SetTrimStackPaths also affects errors created before the call.
+// Passing nil, the default, omits no frames for any prefix.
+//
+// Regardless of the prefixes, frames from the source files of this package
+// itself, other than its tests, are always omitted.
+//
+// SetTrimStackPaths should be called during program initialisation.
+func SetTrimStackPaths(prefixes []string) {
+	trimPaths.Store(append([]string(nil), prefixes...))
+}
+
+// trimFrame reports whether f is omitted from stack traces.
+func trimFrame(f Frame) bool {
+	file := f.file()
+	if strings.HasPrefix(file, pkgDir) && !strings.HasSuffix(file, "_test.go") &&
+		!strings.Contains(file[len(pkgDir):], "/") {
+		return true
+	}
+	prefixes, _ := trimPaths.Load().([]string)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(file, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetStackTrace returns the first non-empty stack trace in err's chain,
+// walking it from outermost to innermost by Cause and Unwrap and asking
+// every error which implements
+//
+//	interface {
+//	        StackTrace() StackTrace
+//	}
+//
+// The outermost trace is usually the most recent one. GetStackTrace
+// returns nil if no error in the chain has a trace or err is nil.
+func GetStackTrace(err error) StackTrace {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		if st, ok := err.(interface{ StackTrace() StackTrace }); ok {
+			if trace := st.StackTrace(); len(trace) > 0 {
+				return trace
+			}
+		}
+	}
+	return nil
+}
+
+// captureFilter holds the func(Frame) bool configured by SetCaptureFilter.
+var captureFilter atomic.Value
+
+// SetCaptureFilter makes the package discard, at the moment a stack trace
+// is recorded, every Frame for which keep returns false. Filtering at
+// capture time reduces the memory retained by each error and the cost of
+// formatting it, but filtered frames are lost permanently: they cannot be
+// recovered when the error is formatted. Passing nil, the default, records
+// every frame.
+//
+// SetCaptureFilter should be called during program initialisation.
+// keep may be called concurrently and must be fast, as it runs for every
+// frame of every recorded stack trace. keep is also applied when a trace is
+// retrieved with StackTrace or printed, so it must give the same answer for
+// a Frame every time; see SetLazyStacks.
+func SetCaptureFilter(keep func(Frame) bool) {
+	captureFilter.Store(keep)
+}
+
+// lazyStacks is non-zero when the work of SetCaptureFilter is deferred.
+var lazyStacks int32
+
+// SetLazyStacks controls whether recording a stack trace is limited to
+// capturing program counters. Stack traces are always stored as program
+// counters, and their functions, files and lines are only resolved when
+// they are retrieved with StackTrace or printed. A filter set with
+// SetCaptureFilter, however, must resolve every frame when the trace is
+// recorded. With lazy stacks enabled, the filter is instead applied when
+// the trace is retrieved, so that errors which are handled and never
+// printed cost no resolution at all, at the price of retaining every
+// frame's program counter. Lazy stacks are disabled by default.
+//
+// SetLazyStacks should be called during program initialisation.
+func SetLazyStacks(lazy bool) {
+	var v int32
+	if lazy {
+		v = 1
+	}
+	atomic.StoreInt32(&lazyStacks, v)
+}
+
+// Bounds and default of the depth set by SetStackDepth.
+const (
+	minStackDepth     = 1
+	maxStackDepth     = 256
+	defaultStackDepth = 32
+)
+
+// stackDepth holds the depth configured by SetStackDepth.
+var stackDepth int32 = defaultStackDepth
+
+// SetStackDepth sets the maximum number of frames recorded in each stack
+// trace, which is 32 by default. Smaller depths make recording cheaper at
+// the price of losing outer frames. n is clamped to the range [1, 256];
+// a value of zero or less therefore records a single frame.
+//
+// SetStackDepth should be called during program initialisation; errors
+// created concurrently with the call may use either depth.
+func SetStackDepth(n int) {
+	if n < minStackDepth {
+		n = minStackDepth
+	}
+	if n > maxStackDepth {
+		n = maxStackDepth
+	}
+	atomic.StoreInt32(&stackDepth, int32(n))
+}
+
+// stackSampleRate holds the rate configured by SetStackSampleRate.
+var stackSampleRate int32
+
+// stackSamples counts, per fingerprint, the errors which were considered
+// for stack capture while sampling was enabled.
+var stackSamples struct {
+	sync.Mutex
+	m map[string]int
+}
+
+// SetStackSampleRate makes the package record the stack trace of only one
+// in n errors with the same fingerprint: the same code and message. The
+// first error with a given fingerprint records its stack trace, as does
+// every n-th after it; the others record none, and their StackTrace method
+// returns an empty StackTrace. This bounds the cost of errors created
+// repeatedly in a tight loop, at the price of their stack traces. A rate of
+// one or less, the default, records every stack trace.
+//
+// Sampling counts every distinct fingerprint seen, so it suits programs
+// whose messages are drawn from a bounded set; messages embedding unique
+// values, such as IDs, defeat it. Calling SetStackSampleRate resets the
+// counts. Errors created by WrapLoc always record their stack trace, as
+// their message depends on it.
+func SetStackSampleRate(n int) {
+	stackSamples.Lock()
+	defer stackSamples.Unlock()
+	stackSamples.m = nil
+	if n < 1 {
+		n = 1
+	}
+	atomic.StoreInt32(&stackSampleRate, int32(n))
+}
+
+// sampleStack reports whether an error with the fingerprint of err should
+// record its stack trace under the rate set by SetStackSampleRate.
+func sampleStack(err error) bool {
+	n := int(atomic.LoadInt32(&stackSampleRate))
+	if n <= 1 || err == nil {
+		return true
+	}
+	fp := sampleFingerprint(err)
+	stackSamples.Lock()
+	defer stackSamples.Unlock()
+	if stackSamples.m == nil {
+		stackSamples.m = make(map[string]int)
+	}
+	count := stackSamples.m[fp]
+	stackSamples.m[fp] = count + 1
+	return count%n == 0
+}
+
+// sampleFingerprint returns the fingerprint sampleStack counts err under.
+// For an error created by WithMessageLazy, whose message must not be
+// computed yet, it is derived from the code, the function computing the
+// message, which identifies the call site, and the fingerprint of the
+// cause.
+func sampleFingerprint(err error) string {
+	if w, ok := err.(*CauseMsgCodeError); ok && w.lazy != nil {
+		pc := reflect.ValueOf(w.lazy.fn).Pointer()
+		return strconv.Itoa(w.Code()) + ":lazy " + strconv.FormatUint(uint64(pc), 16) + ":" + fingerprint(w.cause)
+	}
+	return fingerprint(err)
+}
+
+// callers records the stack trace of the caller of its caller, subject to
+// SetCaptureFilter, for the new error err. Unless err is nil, the trace is
+// only recorded if the chain of err, err included, is no deeper than the
+// limit set by SetMaxChainDepth and the fingerprint of err is sampled under
+// SetStackSampleRate; otherwise callers returns nil. err must therefore be
+// the error which will hold the trace, not the error it wraps.
+func callers(err error) *stack {
+	return callersSkip(err, 1)
+}
+
+// callersSkip is like callers, but records the stack trace of the caller of
+// its caller with skip more frames omitted from its top.
+func callersSkip(err error, skip int) *stack {
+	if max := atomic.LoadInt32(&maxChainDepth); max > 0 && err != nil && ChainLen(err) > int(max) {
+		return nil
+	}
+	if !sampleStack(err) {
+		return nil
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3+skip, pcs[:atomic.LoadInt32(&stackDepth)])
+	if keep, _ := captureFilter.Load().(func(Frame) bool); keep != nil && atomic.LoadInt32(&lazyStacks) == 0 {
+		kept := pcs[:0]
+		for _, pc := range pcs[:n] {
+			if keep(Frame(pc)) {
+				kept = append(kept, pc)
+			}
+		}
+		n = len(kept)
+	}
+	st := make(stack, n)
+	copy(st, pcs[:n])
 	return &st
 }
 
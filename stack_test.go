@@ -248,3 +248,197 @@ func caller() Frame {
 	frame, _ := frames.Next()
 	return Frame(frame.PC)
 }
+
+func TestNormalizeStack(t *testing.T) {
+	tests := []struct {
+		formatted string
+		want      string
+	}{
+		{"", ""},
+		{"no stack here: 42", "no stack here: 42"},
+		{"[stack_test.go:174 stack_test.go:221]", "[stack_test.go:N stack_test.go:N]"},
+		{
+			"error\n" +
+				"github.com/WeiquanWa/errors.TestNormalizeStack\n" +
+				"\t/src/github.com/WeiquanWa/errors/stack_test.go:262",
+			"error\n" +
+				"github.com/WeiquanWa/errors.TestNormalizeStack\n" +
+				"\t/src/github.com/WeiquanWa/errors/stack_test.go:N",
+		},
+	}
+
+	for i, tt := range tests {
+		got := NormalizeStack(tt.formatted)
+		if got != tt.want {
+			t.Errorf("test %d: NormalizeStack(%q): got %q, want %q", i+1, tt.formatted, got, tt.want)
+		}
+	}
+
+	a := fmt.Sprintf("%+v", New("ooh"))
+	b := fmt.Sprintf("%+v", New("ooh"))
+	if a == b {
+		t.Fatalf("expected stacks captured on different lines to differ")
+	}
+	if NormalizeStack(a) != NormalizeStack(b) {
+		t.Errorf("NormalizeStack: got %q and %q, want equal", NormalizeStack(a), NormalizeStack(b))
+	}
+}
+
+func TestSetCaptureFilter(t *testing.T) {
+	SetCaptureFilter(appFrame)
+	filtered := New("filtered").StackTrace()
+	SetCaptureFilter(nil)
+	unfiltered := New("unfiltered").StackTrace()
+
+	if len(filtered) != 1 {
+		t.Errorf("SetCaptureFilter: got %d frames %v, want 1", len(filtered), filtered)
+	}
+	if got := fmt.Sprintf("%n", filtered[0]); got != "TestSetCaptureFilter" {
+		t.Errorf("SetCaptureFilter: got top frame %q, want %q", got, "TestSetCaptureFilter")
+	}
+	if len(unfiltered) <= len(filtered) {
+		t.Errorf("SetCaptureFilter(nil): got %d frames, want more than %d", len(unfiltered), len(filtered))
+	}
+}
+
+func TestSetStackSampleRate(t *testing.T) {
+	SetStackSampleRate(3)
+	defer SetStackSampleRate(0)
+
+	var sampled []bool
+	for i := 0; i < 7; i++ {
+		err := New("tight loop")
+		sampled = append(sampled, len(err.StackTrace()) > 0)
+		if other := New("other"); len(other.StackTrace()) == 0 && i == 0 {
+			t.Errorf("first error with a new fingerprint has no stack trace")
+		}
+	}
+	want := []bool{true, false, false, true, false, false, true}
+	for i := range want {
+		if sampled[i] != want[i] {
+			t.Errorf("SetStackSampleRate(3): got stack traces %v, want %v", sampled, want)
+			break
+		}
+	}
+
+	if err := New("tight loop").SetCode(7); len(err.(*MsgCodeErr).StackTrace()) != 0 {
+		t.Errorf("sampled-out error has a stack trace")
+	}
+	if err := Wrap(New("tight loop"), "outer"); len(err.StackTrace()) == 0 {
+		t.Errorf("Wrap with a new fingerprint has no stack trace")
+	}
+
+	SetStackSampleRate(0)
+	if err := New("tight loop"); len(err.StackTrace()) == 0 {
+		t.Errorf("SetStackSampleRate(0): got no stack trace")
+	}
+}
+
+func TestSetStackDepth(t *testing.T) {
+	defer SetStackDepth(defaultStackDepth)
+
+	deep := func(n int) error {
+		var f func(int) error
+		f = func(i int) error {
+			if i == 0 {
+				return New("deep")
+			}
+			return f(i - 1)
+		}
+		return f(n)
+	}
+
+	tests := []struct {
+		depth int
+		want  int
+	}{
+		{4, 4},
+		{1, 1},
+		{0, 1},
+		{-5, 1},
+		{1000, maxStackDepth},
+	}
+
+	for i, tt := range tests {
+		SetStackDepth(tt.depth)
+		if got := len(deep(300).(*MsgCodeErr).StackTrace()); got != tt.want {
+			t.Errorf("test %d: SetStackDepth(%d): got %d frames, want %d", i+1, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestSetLazyStacks(t *testing.T) {
+	keep := func(f Frame) bool { return f.name() != "testing.tRunner" }
+	SetCaptureFilter(keep)
+	defer SetCaptureFilter(nil)
+
+	eager := New("eager")
+	SetLazyStacks(true)
+	defer SetLazyStacks(false)
+	lazy := New("lazy")
+
+	if n, m := len(*lazy.stack), len(*eager.stack); n <= m {
+		t.Errorf("lazy stack recorded %d frames, want more than the %d of the filtered eager stack", n, m)
+	}
+	if n, m := len(lazy.StackTrace()), len(eager.StackTrace()); n != m {
+		t.Errorf("lazy trace has %d frames, want %d", n, m)
+	}
+	for _, f := range lazy.StackTrace() {
+		if !keep(f) {
+			t.Errorf("lazy trace contains filtered frame %+v", f)
+		}
+	}
+	if top := lazy.StackTrace()[0]; top.name() != "github.com/WeiquanWa/errors.TestSetLazyStacks" {
+		t.Errorf("lazy trace: got top frame %+v, want TestSetLazyStacks", top)
+	}
+}
+
+func TestFrameAccessors(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	err := New("accessors")
+	f := err.StackTrace()[0]
+	if got := f.File(); got != file {
+		t.Errorf("File(): got %q, want %q", got, file)
+	}
+	if got, want := f.Line(), line+1; got != want {
+		t.Errorf("Line(): got %d, want %d", got, want)
+	}
+	if got, want := f.Function(), "github.com/WeiquanWa/errors.TestFrameAccessors"; got != want {
+		t.Errorf("Function(): got %q, want %q", got, want)
+	}
+
+	var unknown Frame
+	if got := unknown.File(); got != "" {
+		t.Errorf("unknown File(): got %q, want %q", got, "")
+	}
+	if got := unknown.Line(); got != 0 {
+		t.Errorf("unknown Line(): got %d, want 0", got)
+	}
+	if got := unknown.Function(); got != "" {
+		t.Errorf("unknown Function(): got %q, want %q", got, "")
+	}
+}
+
+func TestTopFrame(t *testing.T) {
+	if f, ok := (StackTrace{}).TopFrame(); ok {
+		t.Errorf("empty TopFrame(): got (%v, true), want false", f)
+	}
+	if f, ok := OriginFrame(nil); ok {
+		t.Errorf("OriginFrame(nil): got (%v, true), want false", f)
+	}
+	if f, ok := OriginFrame(Expected("miss")); ok {
+		t.Errorf("OriginFrame(Expected): got (%v, true), want false", f)
+	}
+
+	_, _, line, _ := runtime.Caller(0)
+	err := New("origin")
+	f, ok := err.StackTrace().TopFrame()
+	if !ok || f.Line() != line+1 {
+		t.Errorf("TopFrame(): got (%v, %t), want line %d", f, ok, line+1)
+	}
+
+	wrapped := WithMessage(Wrap(err, "middle"), "outer")
+	if f, ok := OriginFrame(wrapped); !ok || f.Line() != line+1 {
+		t.Errorf("OriginFrame(): got (%v, %t), want line %d", f, ok, line+1)
+	}
+}
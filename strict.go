@@ -0,0 +1,37 @@
+package errors
+
+import "sync/atomic"
+
+// strictMessages is non-zero when empty annotation messages are rejected.
+var strictMessages int32
+
+// SetStrictMessages controls whether the functions that annotate an error
+// with a message reject empty messages. When enabled, every function which
+// adds a message it is passed to the chain of an error panics if called
+// with a non-nil error and a message, after formatting, of the empty
+// string. These are Wrap, WithMessage and all their variants, including
+// those taking a code, a format, a skip count or a context, such as
+// WrapWithCode, Codef, WrapLog, WrapCtx, WrapAll and WrapIf, as well as
+// SetMessage and Annotator.Wrap. Calls with a nil error never panic, as
+// they annotate nothing. Functions creating a new error, such as New, and
+// WithMessageLazy and WithPublicMessage, which do not take the message of
+// the chain directly, are unaffected.
+//
+// Strict mode is disabled by default. It is intended to catch accidental
+// empty annotations, which render as ": cause", during development and
+// testing.
+func SetStrictMessages(strict bool) {
+	var v int32
+	if strict {
+		v = 1
+	}
+	atomic.StoreInt32(&strictMessages, v)
+}
+
+// checkMessage panics if strict mode is enabled and message is empty.
+// fn names the calling function for the panic message.
+func checkMessage(fn, message string) {
+	if message == "" && atomic.LoadInt32(&strictMessages) != 0 {
+		panic("errors: empty message passed to " + fn)
+	}
+}
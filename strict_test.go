@@ -0,0 +1,46 @@
+package errors
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStrictMessages(t *testing.T) {
+	SetStrictMessages(true)
+	defer SetStrictMessages(false)
+
+	tests := []struct {
+		name string
+		fn   func()
+	}{
+		{"Wrap", func() { Wrap(io.EOF, "") }},
+		{"Wrapf", func() { Wrapf(io.EOF, "%s", "") }},
+		{"Codef", func() { Codef(404, io.EOF, "") }},
+		{"WithMessage", func() { WithMessage(io.EOF, "") }},
+		{"WithMessagef", func() { WithMessagef(io.EOF, "") }},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if want := "errors: empty message passed to " + tt.name; r != want {
+					t.Errorf("%s: got panic %v, want %q", tt.name, r, want)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+
+	// Non-empty messages and nil errors are always accepted.
+	Wrap(io.EOF, "read")
+	Wrap(nil, "")
+	WithMessage(nil, "")
+}
+
+func TestStrictMessagesDisabled(t *testing.T) {
+	got := Wrap(io.EOF, "").Error()
+	if want := ": EOF"; got != want {
+		t.Errorf("Wrap(io.EOF, \"\"): got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package errors
+
+// NewTemporary returns an error with the supplied message for failures
+// which may succeed if retried, such as a timeout talking to a dependency.
+// Its Temporary method, and IsTemporary, report true for it and for any
+// error wrapping it.
+// NewTemporary also records the stack trace at the point it was called.
+func NewTemporary(message string) *MsgCodeErr {
+	err := &MsgCodeErr{
+		msg:       message,
+		code:      ErrCodeNotDefined,
+		fields:    scopeFields(),
+		temporary: true,
+	}
+	err.stack = callers(err)
+	onNew(err)
+	return err
+}
+
+// WithTemporary marks err as temporary or, if temporary is false, as
+// permanent, overriding whatever the errors in err's chain report. It does
+// not change err's message or code.
+// If err is nil, WithTemporary returns nil.
+func WithTemporary(err error, temporary bool) error {
+	if err == nil {
+		return nil
+	}
+	return &withTemporary{annotation{err}, temporary}
+}
+
+type withTemporary struct {
+	annotation
+	temporary bool
+}
+
+// Temporary reports whether the error was marked temporary.
+func (w *withTemporary) Temporary() bool { return w.temporary }
+
+// Temporary reports whether the error was created by NewTemporary.
+func (f *MsgCodeErr) Temporary() bool { return f.temporary }
+
+// Temporary reports whether the cause of the error is temporary, as
+// determined by IsTemporary.
+func (w *StackError) Temporary() bool { return IsTemporary(w.error) }
+
+// Temporary reports whether the cause of the error is temporary, as
+// determined by IsTemporary.
+func (w *CauseMsgCodeError) Temporary() bool { return IsTemporary(w.cause) }
+
+// IsTemporary reports whether err is temporary, so that a retry loop can
+// treat it as transient. The chain is walked from outermost to innermost,
+// and the first error which was marked with WithTemporary, was created by
+// NewTemporary, or is defined outside this package and implements
+//
+//	interface {
+//	        Temporary() bool
+//	}
+//
+// decides the result, as net.Error values do. IsTemporary returns false if
+// no error decides it or err is nil.
+func IsTemporary(err error) bool {
+	for i := 0; err != nil && i < maxDepth; i, err = i+1, next(err) {
+		switch e := err.(type) {
+		case *withTemporary:
+			return e.temporary
+		case *MsgCodeErr:
+			return e.temporary
+		case interface{ Temporary() bool }:
+			if !isOwn(err) {
+				return e.Temporary()
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package errors
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestTemporary(t *testing.T) {
+	busy := NewTemporary("server busy")
+	dnsErr := &net.DNSError{Err: "server misbehaving", Name: "db", IsTemporary: true}
+	if len(busy.StackTrace()) == 0 {
+		t.Errorf("NewTemporary: got no stack trace")
+	}
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, false},
+		{New("boom"), false},
+		{busy, true},
+		{Wrap(busy, "call backend"), true},
+		{WithMessage(Wrap(busy, "call backend"), "handle request"), true},
+		{NewTemporary("busy").SetCode(503), true},
+		{WithTemporary(io.EOF, true), true},
+		{Wrap(WithTemporary(io.EOF, true), "read"), true},
+		{WithTemporary(Wrap(busy, "call backend"), false), false},
+		{WithHTTPStatus(busy, 503), true},
+		{dnsErr, true},
+		{Wrapf(dnsErr, "dial %s", "db"), true},
+		{Wrap(&net.DNSError{Err: "no such host", Name: "db"}, "dial"), false},
+	}
+
+	for i, tt := range tests {
+		if got := IsTemporary(tt.err); got != tt.want {
+			t.Errorf("test %d: IsTemporary(%v): got %t, want %t", i+1, tt.err, got, tt.want)
+		}
+		if tt.err == nil {
+			continue
+		}
+		if tmp, ok := tt.err.(interface{ Temporary() bool }); ok && tmp.Temporary() != tt.want {
+			t.Errorf("test %d: %v.Temporary(): got %t, want %t", i+1, tt.err, tmp.Temporary(), tt.want)
+		}
+	}
+
+	if got := WithTemporary(nil, true); got != nil {
+		t.Errorf("WithTemporary(nil): got %#v, expected nil", got)
+	}
+}
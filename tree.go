@@ -0,0 +1,122 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// collapseCodes is non-zero when repeated codes are omitted from
+// code-annotated output.
+var collapseCodes int32
+
+// SetCollapseCodes controls whether output which shows a code for each
+// layer of a chain, such as that of Tree and the %+v verb, omits codes
+// equal to the code of the adjacent layer: the layer above for Tree, and
+// the cause for %+v, which prints causes first. Deep chains in which every
+// wrap propagates the same code then show it only once. Collapsing is
+// disabled by default.
+func SetCollapseCodes(collapse bool) {
+	var v int32
+	if collapse {
+		v = 1
+	}
+	atomic.StoreInt32(&collapseCodes, v)
+}
+
+// Tree renders the structure of err as an indented tree, one node per line,
+// with the outermost error at the root. Each node shows the message added
+// at that point of the chain and, when defined, its code; see
+// SetCollapseCodes to omit codes repeated from the node above. Errors which
+// implement
+//
+//	interface {
+//	        Unwrap() []error
+//	}
+//
+// such as those returned by the standard library's Join, are shown as a node
+// with one branch per wrapped error. Layers which add nothing to the message,
+// such as those created by WithStack, are folded into the node below them.
+// If err is nil, Tree returns the empty string.
+//
+// For example, a "load config" error wrapping the Join of a "read config"
+// error and a "parse flags" error renders as
+//
+//	load config [code=500]
+//	└── (2 errors)
+//	    ├── read config [code=404]
+//	    │   └── open /etc/app.conf
+//	    │       └── file does not exist
+//	    └── parse flags
+func Tree(err error) string {
+	if err == nil {
+		return ""
+	}
+	var b strings.Builder
+	writeTree(&b, err, "", "", ErrCodeNotDefined, atomic.LoadInt32(&collapseCodes) != 0, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func writeTree(b *strings.Builder, err error, first, rest string, parentCode int, collapse bool, depth int) {
+	// fold layers which do not change the message into their cause.
+	for ; depth < maxDepth; depth++ {
+		cause := next(err)
+		if cause == nil || cause.Error() != err.Error() {
+			break
+		}
+		err = cause
+	}
+
+	var children []error
+	b.WriteString(first)
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, child := range multi.Unwrap() {
+			if child != nil {
+				children = append(children, child)
+			}
+		}
+		b.WriteString("(" + strconv.Itoa(len(children)) + " errors)")
+	} else if cause := next(err); cause != nil {
+		children = append(children, cause)
+		b.WriteString(treeLabel(err, cause))
+	} else {
+		b.WriteString(err.Error())
+	}
+	code := ErrCodeNotDefined
+	if cErr, ok := err.(interface{ Code() int }); ok {
+		code = cErr.Code()
+	}
+	if code != ErrCodeNotDefined && !(collapse && code == parentCode) {
+		b.WriteString(" [code=" + strconv.Itoa(code) + "]")
+	}
+	b.WriteByte('\n')
+
+	if depth++; depth >= maxDepth {
+		return
+	}
+	for i, child := range children {
+		if i == len(children)-1 {
+			writeTree(b, child, rest+"└── ", rest+"    ", code, collapse, depth)
+		} else {
+			writeTree(b, child, rest+"├── ", rest+"│   ", code, collapse, depth)
+		}
+	}
+}
+
+// treeLabel returns the part of err's message which was added on top of
+// the message of its cause. The separator set by SetCauseSeparator is
+// trimmed from the label, as is the ": " used by fmt.Errorf's %w.
+func treeLabel(err, cause error) string {
+	if w, ok := err.(*CauseMsgCodeError); ok {
+		return w.message()
+	}
+	msg := err.Error()
+	if trimmed := strings.TrimSuffix(msg, cause.Error()); trimmed != msg {
+		for _, sep := range []string{causeSeparator(), ": "} {
+			if label := strings.TrimSuffix(trimmed, sep); label != trimmed && label != "" {
+				return label
+			}
+		}
+	}
+	return msg
+}
@@ -0,0 +1,121 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// joined is a minimal multi-error, as returned by the standard library's Join.
+type joined []error
+
+func (j joined) Error() string {
+	var msgs []string
+	for _, err := range j {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (j joined) Unwrap() []error { return j }
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{{
+		err:  nil,
+		want: "",
+	}, {
+		err:  io.EOF,
+		want: "EOF",
+	}, {
+		err: Wrap(WithMessage(New("root").SetCode(404), "middle"), "outer"),
+		want: "outer [code=404]\n" +
+			"└── middle [code=404]\n" +
+			"    └── root [code=404]",
+	}, {
+		err: Wrap(joined{
+			Wrap(fmt.Errorf("open /etc/app.conf: %w", os.ErrNotExist), "read config").SetCode(404),
+			WithStack(io.EOF),
+			WithMessage(joined{New("a"), New("b").SetCode(2)}, "validate"),
+		}, "load config").SetCode(500),
+		want: "load config [code=500]\n" +
+			"└── (3 errors)\n" +
+			"    ├── read config [code=404]\n" +
+			"    │   └── open /etc/app.conf\n" +
+			"    │       └── file does not exist\n" +
+			"    ├── EOF\n" +
+			"    └── validate\n" +
+			"        └── (2 errors)\n" +
+			"            ├── a\n" +
+			"            └── b [code=2]",
+	}}
+
+	for i, tt := range tests {
+		got := Tree(tt.err)
+		if got != tt.want {
+			t.Errorf("test %d: Tree:\n got:\n%s\nwant:\n%s", i+1, got, tt.want)
+		}
+	}
+}
+
+func TestTreeCollapseCodes(t *testing.T) {
+	err := Wrap(WithMessage(Codef(500, WithMessage(New("root").SetCode(404), "lookup"), "query"), "service"), "handler")
+
+	SetCollapseCodes(true)
+	got := Tree(err)
+	SetCollapseCodes(false)
+
+	want := "handler [code=500]\n" +
+		"└── service\n" +
+		"    └── query\n" +
+		"        └── lookup [code=404]\n" +
+		"            └── root"
+	if got != want {
+		t.Errorf("Tree with SetCollapseCodes(true):\n got:\n%s\nwant:\n%s", got, want)
+	}
+
+	want = "handler [code=500]\n" +
+		"└── service [code=500]\n" +
+		"    └── query [code=500]\n" +
+		"        └── lookup [code=404]\n" +
+		"            └── root [code=404]"
+	if got := Tree(err); got != want {
+		t.Errorf("Tree with SetCollapseCodes(false):\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTreeCauseSeparator(t *testing.T) {
+	SetCauseSeparator(" | ")
+	defer SetCauseSeparator(": ")
+
+	inner := WithMessage(fmt.Errorf("open /etc/app.conf: %w", os.ErrNotExist), "read config")
+	err := Wrap(fmt.Errorf("retry | %w", inner), "load config")
+	want := "load config\n" +
+		"└── retry\n" +
+		"    └── read config\n" +
+		"        └── open /etc/app.conf\n" +
+		"            └── file does not exist"
+	if got := Tree(err); got != want {
+		t.Errorf("Tree with SetCauseSeparator(\" | \"):\n got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func ExampleTree() {
+	err := Wrap(Join(
+		Wrap(fmt.Errorf("open /etc/app.conf: %w", os.ErrNotExist), "read config").SetCode(404),
+		New("parse flags"),
+	), "load config").SetCode(500)
+	fmt.Println(Tree(err))
+
+	// Output:
+	// load config [code=500]
+	// └── (2 errors)
+	//     ├── read config [code=404]
+	//     │   └── open /etc/app.conf
+	//     │       └── file does not exist
+	//     └── parse flags
+}